@@ -1,6 +1,7 @@
 package etl
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
@@ -21,6 +22,7 @@ type PartitionRecordBatch[T any] struct {
 	Offset       interface{}
 	Records      []*T
 	continuation string
+	seq          uint64
 }
 
 func (p *PartitionRecordBatch[T]) Id() string {
@@ -42,21 +44,75 @@ func (p *PartitionRecordBatch[T]) RecordId(recordIdx int) string {
 	return fmt.Sprintf("%s__%d", p.continuation, recordIdx)
 }
 
-type ElementPartition[T any] interface {
+// ElementPartition reads the batches of a partition from a resource of type
+// R, which its shard opens for every chunk of partitions.
+type ElementPartition[T any, R any] interface {
 	Id() string
 	Done() bool
-	NextBatch(resource interface{}, batchSize int) ([]*T, interface{}, error)
+	NextBatch(resource R, batchSize int) ([]*T, interface{}, error)
 	Close() error
 }
 
+// ExclusivePartition is implemented by partitions that may hold a resource of
+// their shard between batches, such as a connection of its pool. Consume
+// reads a partition reporting Exclusive to the end before reading the next
+// partition of its chunk, so a chunk never holds more than one.
+type ExclusivePartition interface {
+	Exclusive() bool
+}
+
+// ContextElementPartition is an ElementPartition whose reads can be cancelled
+// or bounded by a deadline.
+type ContextElementPartition[T any, R any] interface {
+	Id() string
+	Done() bool
+	NextBatchContext(ctx context.Context, resource R, batchSize int) ([]*T, interface{}, error)
+	Close() error
+}
+
+type partitionWithContext[T any, R any] struct {
+	ElementPartition[T, R]
+}
+
+func (p partitionWithContext[T, R]) NextBatchContext(ctx context.Context, resource R, batchSize int) ([]*T, interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return p.NextBatch(resource, batchSize)
+}
+
+// PartitionWithContext adapts partition to ContextElementPartition. Partitions
+// without a NextBatchContext only observe the context between batches.
+func PartitionWithContext[T any, R any](partition ElementPartition[T, R]) ContextElementPartition[T, R] {
+	if contextPartition, ok := partition.(ContextElementPartition[T, R]); ok {
+		return contextPartition
+	}
+	return partitionWithContext[T, R]{partition}
+}
+
 type Closeable interface {
 	Close() error
 }
 
+// NoResource is the resource of shards whose partitions read from nothing
+// their shard opens, such as files.
+type NoResource struct{}
+
+// ResourceShard is a shard whose partitions read from a resource of type R.
+// NewResource opens one for every chunk of partitions, or returns a nil lease
+// when there is nothing to open.
+type ResourceShard[T any, R any] interface {
+	Id() string
+	NewResource() (*Lease[R], error)
+	Partitions() ([]ElementPartition[T, R], error)
+}
+
+// ElementShard is a shard as sources return it, whatever the resource type of
+// its partitions. A ResourceShard implements ConsumeWith by calling
+// ConsumeShard, which keeps that type.
 type ElementShard[T any] interface {
 	Id() string
-	NewResource() (Closeable, error)
-	Partitions() ([]ElementPartition[T], error)
+	ConsumeWith(ctx context.Context, worker *ShardWorker[T], readParallelism int, readBatchSize int, notifyUpdateTo func(WorkerMetrics), maxBatchesPerChunk int) error
 }
 
 type ElementSource[T any] interface {
@@ -102,15 +158,18 @@ type SliceShard[T any] struct {
 func (s *SliceShard[T]) Id() string {
 	return s.id
 }
-func (s *SliceShard[T]) NewResource() (Closeable, error) {
+func (s *SliceShard[T]) NewResource() (*Lease[NoResource], error) {
 	return nil, nil
 }
-func (s *SliceShard[T]) Partitions() ([]ElementPartition[T], error) {
-	return []ElementPartition[T]{&SlicePartition[T]{
+func (s *SliceShard[T]) Partitions() ([]ElementPartition[T, NoResource], error) {
+	return []ElementPartition[T, NoResource]{&SlicePartition[T]{
 		id:   s.Id(),
 		data: s.data,
 	}}, nil
 }
+func (s *SliceShard[T]) ConsumeWith(ctx context.Context, worker *ShardWorker[T], readParallelism int, readBatchSize int, notifyUpdateTo func(WorkerMetrics), maxBatchesPerChunk int) error {
+	return ConsumeShard[T, NoResource](ctx, worker, s, readParallelism, readBatchSize, notifyUpdateTo, maxBatchesPerChunk)
+}
 
 type SlicePartition[T any] struct {
 	id     string
@@ -124,12 +183,15 @@ func (s *SlicePartition[T]) Id() string {
 func (s *SlicePartition[T]) Done() bool {
 	return s.offset >= len(s.data)
 }
-func (s *SlicePartition[T]) NextBatch(resource interface{}, batchSize int) ([]*T, interface{}, error) {
+func (s *SlicePartition[T]) NextBatch(resource NoResource, batchSize int) ([]*T, interface{}, error) {
 	start := s.offset
 	end := min(s.offset+batchSize, len(s.data))
 	s.offset = end
 	return s.data[start:end], s.offset, nil
 }
+func (s *SlicePartition[T]) Resume(offset json.RawMessage) error {
+	return json.Unmarshal(offset, &s.offset)
+}
 func (s *SlicePartition[T]) Close() error {
 	return nil
 }
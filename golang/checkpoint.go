@@ -0,0 +1,374 @@
+package etl
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+type PartitionCheckpoint struct {
+	Shard     string          `json:"shard"`
+	Partition string          `json:"partition"`
+	Offset    json.RawMessage `json:"offset,omitempty"`
+	Done      bool            `json:"done"`
+}
+
+type CheckpointStore interface {
+	Load(shard, partition string) (*PartitionCheckpoint, error)
+	Commit(checkpoint PartitionCheckpoint) error
+	Close() error
+}
+
+type RunCheckpoint struct {
+	Run         string                   `json:"run,omitempty"`
+	Source      string                   `json:"source"`
+	Interrupted bool                     `json:"interrupted"`
+	Error       string                   `json:"error,omitempty"`
+	Reads       map[string]WorkerMetrics `json:"reads"`
+	Writes      map[string]WorkerMetrics `json:"writes"`
+	FinishedAt  time.Time                `json:"finished_at"`
+}
+
+// RunCheckpointer is implemented by stores that also keep the outcome of the
+// last run next to the partition offsets.
+type RunCheckpointer interface {
+	CommitRun(run RunCheckpoint) error
+}
+
+// ResumablePartition is implemented by partitions that can be reopened from
+// an offset previously reported by NextBatch.
+type ResumablePartition interface {
+	Resume(offset json.RawMessage) error
+}
+
+// OffsetChecker is implemented by partitions whose offsets only hold for the
+// way the partition was built, such as the bounds of a key range. Offsets of
+// partitions already done are checked too.
+type OffsetChecker interface {
+	CheckOffset(offset json.RawMessage) error
+}
+
+type checkpointKey struct {
+	shard     string
+	partition string
+}
+
+// fileCheckpointStore appends every commit as a JSON line and replays the log
+// on open, so the latest line for a partition wins. Close compacts the log
+// down to those latest lines.
+type fileCheckpointStore struct {
+	mu          sync.Mutex
+	path        string
+	file        *os.File
+	checkpoints map[checkpointKey]PartitionCheckpoint
+}
+
+// NewFileCheckpointStore keeps the checkpoints of one run at path. Partitions
+// it records as done are skipped by every run using it, so a new run needs a
+// new path and only a resumed run reuses one.
+func NewFileCheckpointStore(path string) (CheckpointStore, error) {
+	checkpoints, end, err := readCheckpointLog(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// a crash can leave the last line half written, which the next commit
+	// would otherwise be appended to
+	if err := file.Truncate(end); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &fileCheckpointStore{
+		path:        path,
+		file:        file,
+		checkpoints: checkpoints,
+	}, nil
+}
+
+// readCheckpointLog replays the log at path line by line, returning the
+// latest checkpoint of every partition and the end of the last whole line.
+func readCheckpointLog(path string) (map[checkpointKey]PartitionCheckpoint, int64, error) {
+	checkpoints := make(map[checkpointKey]PartitionCheckpoint)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return checkpoints, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var end int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return checkpoints, end, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		end += int64(len(line))
+		var checkpoint PartitionCheckpoint
+		if err := json.Unmarshal(line, &checkpoint); err != nil {
+			continue
+		}
+		checkpoints[checkpointKey{checkpoint.Shard, checkpoint.Partition}] = checkpoint
+	}
+}
+
+func (s *fileCheckpointStore) Load(shard, partition string) (*PartitionCheckpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoint, ok := s.checkpoints[checkpointKey{shard, partition}]
+	if !ok {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+func (s *fileCheckpointStore) Commit(checkpoint PartitionCheckpoint) error {
+	line, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing checkpoint to %s: %w", s.path, err)
+	}
+	s.checkpoints[checkpointKey{checkpoint.Shard, checkpoint.Partition}] = checkpoint
+	return nil
+}
+
+// CommitRun writes run to <path>.run.json, replacing the previous run.
+func (s *fileCheckpointStore) CommitRun(run RunCheckpoint) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	tmp := s.path + ".run.json.tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path+".run.json")
+}
+
+// Close rewrites the log with the latest checkpoint of every partition, so
+// the log of a run resumed many times stays as small as its partitions.
+func (s *fileCheckpointStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	if syncErr != nil {
+		return syncErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return s.compact()
+}
+
+func (s *fileCheckpointStore) compact() error {
+	keys := make([]checkpointKey, 0, len(s.checkpoints))
+	for key := range s.checkpoints {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b checkpointKey) int {
+		return cmp.Or(cmp.Compare(a.shard, b.shard), cmp.Compare(a.partition, b.partition))
+	})
+	var log bytes.Buffer
+	for _, key := range keys {
+		line, err := json.Marshal(s.checkpoints[key])
+		if err != nil {
+			return err
+		}
+		log.Write(append(line, '\n'))
+	}
+	tmp := s.path + ".tmp"
+	if err := writeSynced(tmp, log.Bytes()); err != nil {
+		return fmt.Errorf("compacting checkpoints of %s: %w", s.path, err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func writeSynced(path string, data []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+type partitionProgress struct {
+	issued    uint64
+	committed uint64
+	pending   map[uint64]interface{}
+	offset    interface{}
+	finished  bool
+	done      bool
+}
+
+// checkpointTracker follows which batches of a partition have been written.
+// Producers finish batches out of order, so an offset is only committed once
+// every batch read before it has been written too. Without a store it still
+// tells when a partition has been fully written.
+type checkpointTracker struct {
+	mu         sync.Mutex
+	shard      string
+	store      CheckpointStore
+	partitions map[string]*partitionProgress
+}
+
+func newCheckpointTracker(shard string, store CheckpointStore) *checkpointTracker {
+	return &checkpointTracker{
+		shard:      shard,
+		store:      store,
+		partitions: make(map[string]*partitionProgress),
+	}
+}
+
+func (c *checkpointTracker) progress(partition string) *partitionProgress {
+	p, ok := c.partitions[partition]
+	if !ok {
+		p = &partitionProgress{pending: make(map[uint64]interface{})}
+		c.partitions[partition] = p
+	}
+	return p
+}
+
+func (c *checkpointTracker) next(partition string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.progress(partition)
+	p.issued++
+	return p.issued
+}
+
+// finish marks the partition as fully read and reports whether it is now done.
+func (c *checkpointTracker) finish(partition string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.progress(partition)
+	if p.finished {
+		return false, nil
+	}
+	p.finished = true
+	if p.committed == p.issued {
+		return true, c.commit(partition, p, p.offset, true)
+	}
+	return false, nil
+}
+
+// ack marks the batch seq as written and reports whether the partition is now done.
+func (c *checkpointTracker) ack(partition string, seq uint64, offset interface{}) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.progress(partition)
+	p.pending[seq] = offset
+	var (
+		last     interface{}
+		advanced bool
+	)
+	for {
+		next, ok := p.pending[p.committed+1]
+		if !ok {
+			break
+		}
+		delete(p.pending, p.committed+1)
+		p.committed++
+		last, advanced = next, true
+	}
+	if !advanced {
+		return false, nil
+	}
+	done := p.finished && p.committed == p.issued
+	return done, c.commit(partition, p, last, done)
+}
+
+func (c *checkpointTracker) offset(partition string) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.progress(partition).offset
+}
+
+func (c *checkpointTracker) commit(partition string, p *partitionProgress, offset interface{}, done bool) error {
+	p.offset = offset
+	p.done = done
+	if c.store == nil {
+		return nil
+	}
+	raw, err := json.Marshal(offset)
+	if err != nil {
+		return err
+	}
+	return c.store.Commit(PartitionCheckpoint{
+		Shard:     c.shard,
+		Partition: partition,
+		Offset:    raw,
+		Done:      done,
+	})
+}
+
+// restorePartitions drops partitions already committed as done and resumes
+// the rest from their last committed offset.
+func restorePartitions[T any, R any](c *checkpointTracker, partitions []ElementPartition[T, R]) ([]ElementPartition[T, R], []ElementPartition[T, R], error) {
+	var pending, done []ElementPartition[T, R]
+	for _, partition := range partitions {
+		checkpoint, err := c.store.Load(c.shard, partition.Id())
+		if err != nil {
+			return nil, nil, err
+		}
+		if checkpoint == nil {
+			pending = append(pending, partition)
+			continue
+		}
+		hasOffset := len(checkpoint.Offset) > 0 && string(checkpoint.Offset) != "null"
+		if checker, ok := partition.(OffsetChecker); ok && hasOffset {
+			if err := checker.CheckOffset(checkpoint.Offset); err != nil {
+				return nil, nil, fmt.Errorf("resuming partition %s: %w", partition.Id(), err)
+			}
+		}
+		if checkpoint.Done {
+			done = append(done, partition)
+			continue
+		}
+		if hasOffset {
+			resumable, ok := partition.(ResumablePartition)
+			if !ok {
+				return nil, nil, fmt.Errorf("partition %s has a checkpoint but cannot be resumed", partition.Id())
+			}
+			if err := resumable.Resume(checkpoint.Offset); err != nil {
+				return nil, nil, fmt.Errorf("resuming partition %s: %w", partition.Id(), err)
+			}
+		}
+		c.mu.Lock()
+		c.progress(partition.Id()).offset = checkpoint.Offset
+		c.mu.Unlock()
+		pending = append(pending, partition)
+	}
+	return pending, done, nil
+}
@@ -0,0 +1,185 @@
+package etl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type memoryWriter struct {
+	mu      sync.Mutex
+	records map[any]interface{}
+	errors  map[any]error
+}
+
+func newMemoryWriter() *memoryWriter {
+	return &memoryWriter{records: map[any]interface{}{}, errors: map[any]error{}}
+}
+
+func (m *memoryWriter) Append(id any, record interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[id] = record
+	return nil
+}
+
+func (m *memoryWriter) AppendError(id any, err error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[id] = err
+	return nil
+}
+
+func (m *memoryWriter) Close() error {
+	return nil
+}
+
+type passThrough[T any] struct{}
+
+func (p passThrough[T]) Process(record *T) *ProcessedRecord {
+	return &ProcessedRecord{Id: *record, Record: record}
+}
+
+func (p passThrough[T]) ProcessBatch(records []*T) ([]*ProcessedRecord, error) {
+	var processed []*ProcessedRecord
+	for _, record := range records {
+		processed = append(processed, p.Process(record))
+	}
+	return processed, nil
+}
+
+func TestCheckpoints(t *testing.T) {
+	t.Run("TestFileCheckpointStoreReplaysLatest", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+		store, err := NewFileCheckpointStore(path)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Commit(PartitionCheckpoint{Shard: "s", Partition: "p", Offset: []byte("10")}))
+		assert.NoError(t, store.Commit(PartitionCheckpoint{Shard: "s", Partition: "p", Offset: []byte("20"), Done: true}))
+		assert.NoError(t, store.Close())
+
+		reopened, err := NewFileCheckpointStore(path)
+		assert.NoError(t, err)
+		defer reopened.Close()
+		checkpoint, err := reopened.Load("s", "p")
+		assert.NoError(t, err)
+		assert.Equal(t, "20", string(checkpoint.Offset))
+		assert.True(t, checkpoint.Done)
+		missing, err := reopened.Load("s", "other")
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("TestCloseCompactsTheLog", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+		store, err := NewFileCheckpointStore(path)
+		assert.NoError(t, err)
+		for offset := range 10 {
+			assert.NoError(t, store.Commit(PartitionCheckpoint{Shard: "s", Partition: "b", Offset: []byte(fmt.Sprint(offset))}))
+			assert.NoError(t, store.Commit(PartitionCheckpoint{Shard: "s", Partition: "a", Offset: []byte(fmt.Sprint(offset))}))
+		}
+		assert.NoError(t, store.Close())
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"shard":"s","partition":"a","offset":9,"done":false}`+"\n"+
+			`{"shard":"s","partition":"b","offset":9,"done":false}`+"\n", string(data))
+	})
+
+	t.Run("TestHalfWrittenLineIsDropped", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+		log := `{"shard":"s","partition":"a","offset":1,"done":false}` + "\n" + `{"shard":"s","partition":"a","off`
+		assert.NoError(t, os.WriteFile(path, []byte(log), 0644))
+
+		store, err := NewFileCheckpointStore(path)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Commit(PartitionCheckpoint{Shard: "s", Partition: "b", Offset: []byte("2")}))
+		// reads the log as a crash would leave it, without compacting it
+		checkpoints, _, err := readCheckpointLog(path)
+		assert.NoError(t, err)
+		assert.Len(t, checkpoints, 2)
+		assert.Equal(t, "2", string(checkpoints[checkpointKey{"s", "b"}].Offset))
+		assert.NoError(t, store.Close())
+	})
+
+	t.Run("TestTrackerCommitsContiguousBatchesOnly", func(t *testing.T) {
+		store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.jsonl"))
+		assert.NoError(t, err)
+		defer store.Close()
+		tracker := newCheckpointTracker("s", store)
+		first, second, third := tracker.next("p"), tracker.next("p"), tracker.next("p")
+
+		done, err := tracker.ack("p", second, 20)
+		assert.NoError(t, err)
+		assert.False(t, done)
+		checkpoint, _ := store.Load("s", "p")
+		assert.Nil(t, checkpoint)
+
+		_, err = tracker.ack("p", first, 10)
+		assert.NoError(t, err)
+		checkpoint, _ = store.Load("s", "p")
+		assert.Equal(t, "20", string(checkpoint.Offset))
+		assert.False(t, checkpoint.Done)
+
+		done, err = tracker.finish("p")
+		assert.NoError(t, err)
+		assert.False(t, done, "a batch is still being written")
+		done, err = tracker.ack("p", third, 30)
+		assert.NoError(t, err)
+		assert.True(t, done)
+		checkpoint, _ = store.Load("s", "p")
+		assert.Equal(t, "30", string(checkpoint.Offset))
+		assert.True(t, checkpoint.Done)
+	})
+
+	t.Run("TestShardWorkerResumesFromCheckpoint", func(t *testing.T) {
+		var data []*int
+		for i := range 10 {
+			data = append(data, &i)
+		}
+		shard := &SliceShard[int]{id: "shard", data: data}
+		store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.jsonl"))
+		assert.NoError(t, err)
+		defer store.Close()
+		assert.NoError(t, store.Commit(PartitionCheckpoint{Shard: "shard", Partition: "shard", Offset: []byte("4")}))
+
+		writer := newMemoryWriter()
+		worker := NewShardWorker[int]("shard", 10, zap.NewNop()).WithCheckpoints(store)
+		ctx := context.Background()
+		done := make(chan error)
+		go func() {
+			done <- worker.Produce(ctx, passThrough[int]{}, func(string) (ElementWriter, error) { return writer, nil }, 1, func(WorkerMetrics) {})
+		}()
+		assert.NoError(t, worker.Consume(ctx, shard, 1, 3, func(WorkerMetrics) {}, 0))
+		assert.NoError(t, <-done)
+
+		assert.Len(t, writer.records, 6)
+		for i := range 4 {
+			assert.NotContains(t, writer.records, i, fmt.Sprintf("record %d is before the checkpoint", i))
+		}
+		checkpoint, _ := store.Load("shard", "shard")
+		assert.Equal(t, "10", string(checkpoint.Offset))
+		assert.True(t, checkpoint.Done)
+	})
+
+	t.Run("TestResumedRunKeepsEarlierOutput", func(t *testing.T) {
+		directory := t.TempDir()
+		for attempt := range 2 {
+			sink, err := NewFSSinkFactory(directory, ENCODER_JSON)("shard_producer_0")
+			assert.NoError(t, err)
+			assert.NoError(t, sink.Append(attempt, attempt))
+			assert.NoError(t, sink.Close())
+		}
+		files, err := filepath.Glob(filepath.Join(directory, "*.json.gz"))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(directory, "shard_producer_0.1.json.gz"),
+			filepath.Join(directory, "shard_producer_0.json.gz"),
+		}, files)
+	})
+}
@@ -0,0 +1,83 @@
+package etl
+
+import (
+	"fmt"
+	"os"
+)
+
+type DeadLetter struct {
+	Id        any         `json:"id"`
+	Shard     string      `json:"shard"`
+	Partition string      `json:"partition"`
+	Offset    interface{} `json:"offset"`
+	Record    interface{} `json:"record,omitempty"`
+	Error     string      `json:"error"`
+	ErrorType string      `json:"error_type"`
+	Run       string      `json:"run,omitempty"`
+	ReplayOf  string      `json:"replay_of,omitempty"`
+}
+
+func NewDeadLetter[T any](batch *PartitionRecordBatch[T], id any, input interface{}, err error) *DeadLetter {
+	return &DeadLetter{
+		Id:        id,
+		Shard:     batch.Shard,
+		Partition: batch.Partition,
+		Offset:    batch.Offset,
+		Record:    input,
+		Error:     err.Error(),
+		ErrorType: fmt.Sprintf("%T", err),
+	}
+}
+
+type DeadLetterWriter interface {
+	Write(*DeadLetter) error
+	Close() error
+}
+
+type DeadLetterWriterFactory = func(string) (DeadLetterWriter, error)
+
+type fsDeadLetterWriter struct {
+	sink *fsSink
+}
+
+func NewFileDeadLetterWriter(path string) (DeadLetterWriter, error) {
+	sink, err := NewFileElementWriter(path, ENCODER_JSON)
+	if err != nil {
+		return nil, err
+	}
+	return &fsDeadLetterWriter{sink: sink.(*fsSink)}, nil
+}
+
+func (w *fsDeadLetterWriter) Write(deadLetter *DeadLetter) error {
+	return w.sink.append(deadLetter)
+}
+
+func (w *fsDeadLetterWriter) Location() string {
+	return w.sink.Location()
+}
+
+func (w *fsDeadLetterWriter) Flush() error {
+	return w.sink.Flush()
+}
+
+func (w *fsDeadLetterWriter) Close() error {
+	return w.sink.Close()
+}
+
+const deadLetterSuffix = ".dead_letters.json.gz"
+
+func NewFSDeadLetterFactory(directory string) DeadLetterWriterFactory {
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		if !os.IsExist(err) {
+			panic(err)
+		}
+	}
+	return func(partitionKey string) (DeadLetterWriter, error) {
+		path, err := attemptPath(directory, partitionKey, deadLetterSuffix)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileDeadLetterWriter(path)
+	}
+}
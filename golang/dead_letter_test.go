@@ -0,0 +1,72 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type memoryDeadLetters struct {
+	letters []*DeadLetter
+}
+
+func (m *memoryDeadLetters) Write(deadLetter *DeadLetter) error {
+	m.letters = append(m.letters, deadLetter)
+	return nil
+}
+
+func (m *memoryDeadLetters) Close() error {
+	return nil
+}
+
+// failingOdd fails the records with an odd id without setting their Input.
+type failingOdd struct{}
+
+func (failingOdd) Process(record *DBRecord[int]) *ProcessedRecord {
+	if record.Id.(int)%2 == 1 {
+		return &ProcessedRecord{Id: record.Id, Err: errors.New("odd")}
+	}
+	return &ProcessedRecord{Id: record.Id, Record: record.Record}
+}
+
+func (p failingOdd) ProcessBatch(records []*DBRecord[int]) ([]*ProcessedRecord, error) {
+	var processed []*ProcessedRecord
+	// outputs in reverse order, so they can only be matched by id
+	for i := len(records) - 1; i >= 0; i-- {
+		processed = append(processed, p.Process(records[i]))
+	}
+	return processed, nil
+}
+
+func TestDeadLetters(t *testing.T) {
+	t.Run("TestRecordErrorsKeepTheirInput", func(t *testing.T) {
+		deadLetters := &memoryDeadLetters{}
+		worker := NewShardWorker[DBRecord[int]]("shard", 1, zap.NewNop()).
+			WithDeadLetters(func(string) (DeadLetterWriter, error) { return deadLetters, nil })
+		var records []*DBRecord[int]
+		for id := range 4 {
+			value := id * 10
+			records = append(records, &DBRecord[int]{Id: id, Record: &value})
+		}
+		worker.buffer <- PartitionRecordBatch[DBRecord[int]]{Shard: "shard", Partition: "db.t", Records: records}
+		close(worker.buffer)
+
+		err := worker.Produce(context.Background(), failingOdd{}, func(string) (ElementWriter, error) { return newMemoryWriter(), nil }, 1, func(WorkerMetrics) {})
+		assert.NoError(t, err)
+		assert.Len(t, deadLetters.letters, 2)
+		for _, letter := range deadLetters.letters {
+			assert.Equal(t, records[letter.Id.(int)], letter.Record)
+		}
+	})
+
+	t.Run("TestMatchesOutputsByPosition", func(t *testing.T) {
+		batch := &PartitionRecordBatch[int]{Records: []*int{new(int), new(int)}}
+		outputs := []*ProcessedRecord{{Id: "a"}, {Id: "b", Err: errors.New("failed")}}
+		fillInputs(batch, outputs)
+		assert.Nil(t, outputs[0].Input)
+		assert.Same(t, batch.Records[1], outputs[1].Input)
+	})
+}
@@ -1,8 +1,8 @@
 package etl
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 )
 
@@ -11,6 +11,32 @@ type ElementProcessor[T any] interface {
 	ProcessBatch([]*T) ([]*ProcessedRecord, error)
 }
 
+// ContextElementProcessor is an ElementProcessor whose batches can be
+// cancelled or bounded by a deadline.
+type ContextElementProcessor[T any] interface {
+	ProcessBatchContext(ctx context.Context, records []*T) ([]*ProcessedRecord, error)
+}
+
+type processorWithContext[T any] struct {
+	ElementProcessor[T]
+}
+
+func (p processorWithContext[T]) ProcessBatchContext(ctx context.Context, records []*T) ([]*ProcessedRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.ProcessBatch(records)
+}
+
+// ProcessorWithContext adapts processor to ContextElementProcessor. Processors
+// without a ProcessBatchContext only observe the context between batches.
+func ProcessorWithContext[T any](processor ElementProcessor[T]) ContextElementProcessor[T] {
+	if contextProcessor, ok := processor.(ContextElementProcessor[T]); ok {
+		return contextProcessor
+	}
+	return processorWithContext[T]{processor}
+}
+
 type IdentityMapper[T any] struct {
 }
 
@@ -51,6 +77,9 @@ func (s *HTTPMapper[T]) Process(t *T) *ProcessedRecord {
 	return results[0]
 }
 func (s *HTTPMapper[T]) ProcessBatch(records []*T) ([]*ProcessedRecord, error) {
+	return s.ProcessBatchContext(context.Background(), records)
+}
+func (s *HTTPMapper[T]) ProcessBatchContext(ctx context.Context, records []*T) ([]*ProcessedRecord, error) {
 	if len(records) == 0 {
 		return []*ProcessedRecord{}, nil
 	}
@@ -58,6 +87,7 @@ func (s *HTTPMapper[T]) ProcessBatch(records []*T) ([]*ProcessedRecord, error) {
 	if err != nil {
 		return nil, err
 	}
+	request = request.WithContext(ctx)
 
 	response, err := http.DefaultClient.Do(request)
 	if err != nil {
@@ -65,7 +95,7 @@ func (s *HTTPMapper[T]) ProcessBatch(records []*T) ([]*ProcessedRecord, error) {
 	}
 	defer response.Body.Close()
 	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+		return nil, &HTTPStatusError{StatusCode: response.StatusCode}
 	}
 	var transformedRecords []map[string]interface{}
 	err = json.NewDecoder(response.Body).Decode(&transformedRecords)
@@ -18,24 +18,50 @@ type ElementWriter interface {
 	Close() error
 }
 
+// LabeledWriter is implemented by writers that keep the labels of a record
+// in the envelope written around it.
+type LabeledWriter interface {
+	AppendLabeled(id any, labels Labels, record interface{}) error
+	AppendErrorLabeled(id any, labels Labels, err error) error
+}
+
+// ReplayWriter is implemented by writers recording in the envelope of every
+// record the run whose dead letters it replays.
+type ReplayWriter interface {
+	SetReplayOf(run string)
+}
+
+type Flusher interface {
+	Flush() error
+}
+
+// Locator is implemented by writers that can tell where their output lives.
+type Locator interface {
+	Location() string
+}
+
 type fsSink struct {
-	encoder RecordEncoder
-	file    *os.File
-	gzip    *gzip.Writer
-	writer  *bufio.Writer
+	path     string
+	encoder  RecordEncoder
+	file     *os.File
+	gzip     *gzip.Writer
+	writer   *bufio.Writer
+	replayOf string
 }
 
+// NewFileElementWriter writes a gzip stream of JSON lines to path. An
+// existing file is appended to, never truncated.
 func NewFileElementWriter(path string, encoder RecordEncoder) (ElementWriter, error) {
-	file, err := os.Create(path)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
 	}
 	gz := gzip.NewWriter(file)
 	writer := bufio.NewWriter(gz)
-	return &fsSink{encoder, file, gz, writer}, nil
+	return &fsSink{path: path, encoder: encoder, file: file, gzip: gz, writer: writer}, nil
 }
 
-func (f *fsSink) append(data map[string]any) error {
+func (f *fsSink) append(data interface{}) error {
 	bytes, err := f.encoder(data)
 	if err != nil {
 		return err
@@ -49,19 +75,49 @@ func (f *fsSink) append(data map[string]any) error {
 }
 
 func (f *fsSink) Append(id any, data interface{}) error {
+	return f.AppendLabeled(id, nil, data)
+}
+
+func (f *fsSink) AppendError(id any, recordErr error) error {
+	return f.AppendErrorLabeled(id, nil, recordErr)
+}
+
+func (f *fsSink) AppendLabeled(id any, labels Labels, data interface{}) error {
+	return f.appendEnvelope(id, labels, "record", data)
+}
+
+func (f *fsSink) AppendErrorLabeled(id any, labels Labels, recordErr error) error {
+	return f.appendEnvelope(id, labels, "error", recordErr.Error())
+}
+
+func (f *fsSink) appendEnvelope(id any, labels Labels, key string, value interface{}) error {
 	idText, err := json.Marshal(id)
 	if err != nil {
 		return err
 	}
-	return f.append(map[string]any{"id": idText, "record": data})
+	envelope := map[string]any{"id": idText, key: value}
+	if len(labels) > 0 {
+		envelope["labels"] = labels
+	}
+	if f.replayOf != "" {
+		envelope["replay_of"] = f.replayOf
+	}
+	return f.append(envelope)
 }
 
-func (f *fsSink) AppendError(id any, recordErr error) error {
-	idText, err := json.Marshal(id)
-	if err != nil {
+func (f *fsSink) SetReplayOf(run string) {
+	f.replayOf = run
+}
+
+func (f *fsSink) Location() string {
+	return f.path
+}
+
+func (f *fsSink) Flush() error {
+	if err := f.writer.Flush(); err != nil {
 		return err
 	}
-	return f.append(map[string]any{"id": idText, "error": recordErr})
+	return f.gzip.Flush()
 }
 
 func (f *fsSink) Close() error {
@@ -85,7 +141,27 @@ func NewFSSinkFactory(directory string, encoder RecordEncoder) ElementWriterFact
 		}
 	}
 	return func(partitionKey string) (ElementWriter, error) {
-		path := fmt.Sprintf("%s/%s.json.gz", directory, partitionKey)
+		path, err := attemptPath(directory, partitionKey, ".json.gz")
+		if err != nil {
+			return nil, err
+		}
 		return NewFileElementWriter(path, encoder)
 	}
 }
+
+// attemptPath names the output of key in directory, numbering the outputs
+// of later attempts so a resumed run keeps what earlier attempts wrote:
+// key.json.gz, then key.1.json.gz, key.2.json.gz...
+func attemptPath(directory, key, suffix string) (string, error) {
+	path := fmt.Sprintf("%s/%s%s", directory, key, suffix)
+	for attempt := 1; ; attempt++ {
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		path = fmt.Sprintf("%s/%s.%d%s", directory, key, attempt, suffix)
+	}
+}
@@ -0,0 +1,232 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type ErrorBudgetAction int
+
+const (
+	ErrorBudgetCancel ErrorBudgetAction = iota
+	ErrorBudgetPause
+)
+
+// ErrorBudgetLimits disables any threshold left at zero. MaxErrorRate is
+// evaluated over the budget window once at least MinProcessed records fell in it.
+type ErrorBudgetLimits struct {
+	MaxErrors    int
+	MaxErrorRate float64
+	MinProcessed int
+}
+
+type ErrorBudgetConfig struct {
+	Global   ErrorBudgetLimits
+	PerShard ErrorBudgetLimits
+	Window   time.Duration
+	Action   ErrorBudgetAction
+}
+
+var ErrErrorBudgetExceeded = errors.New("error budget exceeded")
+
+type ErrorBudgetExceededError struct {
+	Shard     string
+	Errors    int
+	Processed int
+	Rate      float64
+	Reason    string
+}
+
+func (e *ErrorBudgetExceededError) Error() string {
+	scope := "run"
+	if e.Shard != "" {
+		scope = "shard " + e.Shard
+	}
+	return fmt.Sprintf("%s: %s %s (%d errors, %d processed in window, rate %.3f)", ErrErrorBudgetExceeded, scope, e.Reason, e.Errors, e.Processed, e.Rate)
+}
+
+func (e *ErrorBudgetExceededError) Unwrap() error {
+	return ErrErrorBudgetExceeded
+}
+
+type budgetBucket struct {
+	at        time.Time
+	processed int
+	errors    int
+}
+
+type budgetCounter struct {
+	errors  int
+	buckets []budgetBucket
+}
+
+func (c *budgetCounter) add(now time.Time, window time.Duration, metrics WorkerMetrics) (int, int) {
+	c.errors += metrics.Errors
+	c.buckets = append(c.buckets, budgetBucket{now, metrics.Processed, metrics.Errors})
+	cutoff := now.Add(-window)
+	expired := 0
+	for expired < len(c.buckets) && c.buckets[expired].at.Before(cutoff) {
+		expired++
+	}
+	c.buckets = c.buckets[expired:]
+	var processed, errors int
+	for _, bucket := range c.buckets {
+		processed += bucket.processed
+		errors += bucket.errors
+	}
+	return processed, errors
+}
+
+func (l ErrorBudgetLimits) check(shard string, total, processed, errors int) error {
+	if l.MaxErrors > 0 && total > l.MaxErrors {
+		return &ErrorBudgetExceededError{Shard: shard, Errors: total, Processed: processed, Reason: fmt.Sprintf("more than %d errors", l.MaxErrors)}
+	}
+	if l.MaxErrorRate > 0 && processed > 0 && processed >= l.MinProcessed {
+		rate := float64(errors) / float64(processed)
+		if rate > l.MaxErrorRate {
+			return &ErrorBudgetExceededError{Shard: shard, Errors: errors, Processed: processed, Rate: rate, Reason: fmt.Sprintf("error rate above %.3f", l.MaxErrorRate)}
+		}
+	}
+	return nil
+}
+
+// ErrorBudget watches the write metrics of a run. Depending on its action a
+// breach either cancels the run or pauses consumption until Resume is called:
+// a breach of the global limits pauses every shard, a breach of the per-shard
+// limits only the shard that exceeded them.
+type ErrorBudget struct {
+	config ErrorBudgetConfig
+	now    func() time.Time
+
+	mu            sync.Mutex
+	global        *budgetCounter
+	shards        map[string]*budgetCounter
+	breach        error
+	resumed       chan struct{}
+	shardBreaches map[string]error
+	shardResumed  map[string]chan struct{}
+}
+
+func NewErrorBudget(config ErrorBudgetConfig) *ErrorBudget {
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	return &ErrorBudget{
+		config:        config,
+		now:           time.Now,
+		global:        &budgetCounter{},
+		shards:        make(map[string]*budgetCounter),
+		shardBreaches: make(map[string]error),
+		shardResumed:  make(map[string]chan struct{}),
+	}
+}
+
+func (b *ErrorBudget) Action() ErrorBudgetAction {
+	return b.config.Action
+}
+
+// Observe records metrics written for shard and returns the breach the first
+// time a threshold is exceeded, by the run or by shard.
+func (b *ErrorBudget) Observe(shard string, metrics WorkerMetrics) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	processed, errors := b.global.add(now, b.config.Window, metrics)
+	breach := b.config.Global.check("", b.global.errors, processed, errors)
+
+	counter, ok := b.shards[shard]
+	if !ok {
+		counter = &budgetCounter{}
+		b.shards[shard] = counter
+	}
+	processed, errors = counter.add(now, b.config.Window, metrics)
+	if b.breach != nil {
+		return nil
+	}
+	if breach != nil {
+		b.breach = breach
+		if b.config.Action == ErrorBudgetPause {
+			b.resumed = make(chan struct{})
+		}
+		return breach
+	}
+	breach = b.config.PerShard.check(shard, counter.errors, processed, errors)
+	if breach == nil || b.shardBreaches[shard] != nil {
+		return nil
+	}
+	b.shardBreaches[shard] = breach
+	if b.config.Action == ErrorBudgetPause {
+		b.shardResumed[shard] = make(chan struct{})
+	}
+	return breach
+}
+
+// Paused reports whether the reads of the run or of any shard are paused.
+func (b *ErrorBudget) Paused() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.resumed != nil || len(b.shardResumed) > 0
+}
+
+// Resume lifts every pause and starts the budget afresh.
+func (b *ErrorBudget) Resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.resumed != nil {
+		close(b.resumed)
+		b.resumed = nil
+	}
+	for _, resumed := range b.shardResumed {
+		close(resumed)
+	}
+	b.breach = nil
+	b.global = &budgetCounter{}
+	b.shards = make(map[string]*budgetCounter)
+	b.shardBreaches = make(map[string]error)
+	b.shardResumed = make(map[string]chan struct{})
+}
+
+// Wait blocks while the run is paused.
+func (b *ErrorBudget) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	resumed := b.resumed
+	b.mu.Unlock()
+	return waitResumed(ctx, resumed)
+}
+
+// Gate returns the gate of shard, which blocks while the run or shard is
+// paused.
+func (b *ErrorBudget) Gate(shard string) ConsumeGate {
+	return shardGate{budget: b, shard: shard}
+}
+
+type shardGate struct {
+	budget *ErrorBudget
+	shard  string
+}
+
+func (g shardGate) Wait(ctx context.Context) error {
+	g.budget.mu.Lock()
+	resumed := g.budget.resumed
+	if resumed == nil {
+		resumed = g.budget.shardResumed[g.shard]
+	}
+	g.budget.mu.Unlock()
+	// Resume lifts both pauses at once
+	return waitResumed(ctx, resumed)
+}
+
+func waitResumed(ctx context.Context, resumed chan struct{}) error {
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,80 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorBudget(t *testing.T) {
+	clock := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	newBudget := func(config ErrorBudgetConfig) *ErrorBudget {
+		budget := NewErrorBudget(config)
+		budget.now = func() time.Time { return clock }
+		return budget
+	}
+
+	t.Run("TestAbsoluteGlobalLimit", func(t *testing.T) {
+		budget := newBudget(ErrorBudgetConfig{Global: ErrorBudgetLimits{MaxErrors: 5}})
+		assert.NoError(t, budget.Observe("a", WorkerMetrics{Processed: 10, Successes: 7, Errors: 3}))
+		breach := budget.Observe("b", WorkerMetrics{Processed: 10, Successes: 7, Errors: 3})
+		assert.True(t, errors.Is(breach, ErrErrorBudgetExceeded))
+		assert.NoError(t, budget.Observe("b", WorkerMetrics{Processed: 10, Errors: 10}), "a breach is only reported once")
+	})
+
+	t.Run("TestRollingShardRate", func(t *testing.T) {
+		budget := newBudget(ErrorBudgetConfig{
+			PerShard: ErrorBudgetLimits{MaxErrorRate: 0.5, MinProcessed: 20},
+			Window:   time.Minute,
+		})
+		assert.NoError(t, budget.Observe("a", WorkerMetrics{Processed: 10, Errors: 10}), "below the minimum sample")
+		clock = clock.Add(2 * time.Minute)
+		assert.NoError(t, budget.Observe("a", WorkerMetrics{Processed: 20, Successes: 15, Errors: 5}), "old errors left the window")
+		breach := budget.Observe("a", WorkerMetrics{Processed: 20, Errors: 20})
+		var exceeded *ErrorBudgetExceededError
+		assert.True(t, errors.As(breach, &exceeded))
+		assert.Equal(t, "a", exceeded.Shard)
+	})
+
+	t.Run("TestPauseUntilResumed", func(t *testing.T) {
+		budget := newBudget(ErrorBudgetConfig{Global: ErrorBudgetLimits{MaxErrors: 1}, Action: ErrorBudgetPause})
+		assert.Error(t, budget.Observe("a", WorkerMetrics{Processed: 2, Errors: 2}))
+		assert.True(t, budget.Paused())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Error(t, budget.Wait(ctx))
+
+		go budget.Resume()
+		assert.NoError(t, budget.Wait(context.Background()))
+		assert.False(t, budget.Paused())
+	})
+	t.Run("TestShardBreachPausesOnlyItsShard", func(t *testing.T) {
+		budget := newBudget(ErrorBudgetConfig{PerShard: ErrorBudgetLimits{MaxErrors: 1}, Action: ErrorBudgetPause})
+		assert.Error(t, budget.Observe("a", WorkerMetrics{Processed: 2, Errors: 2}))
+		assert.True(t, budget.Paused())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Error(t, budget.Gate("a").Wait(ctx))
+		assert.NoError(t, budget.Gate("b").Wait(ctx), "other shards keep reading")
+		assert.NoError(t, budget.Wait(ctx))
+
+		assert.Error(t, budget.Observe("b", WorkerMetrics{Processed: 2, Errors: 2}), "each shard breaches on its own")
+		assert.NoError(t, budget.Observe("b", WorkerMetrics{Processed: 2, Errors: 2}))
+		go budget.Resume()
+		assert.NoError(t, budget.Gate("a").Wait(context.Background()))
+		assert.False(t, budget.Paused())
+	})
+
+	t.Run("TestGlobalBreachPausesEveryShard", func(t *testing.T) {
+		budget := newBudget(ErrorBudgetConfig{Global: ErrorBudgetLimits{MaxErrors: 1}, Action: ErrorBudgetPause})
+		assert.Error(t, budget.Observe("a", WorkerMetrics{Processed: 2, Errors: 2}))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Error(t, budget.Gate("b").Wait(ctx))
+	})
+}
@@ -0,0 +1,65 @@
+package etl
+
+import (
+	"time"
+)
+
+type EventType string
+
+const (
+	EventShardStarted    EventType = "shard_started"
+	EventPartitionOpened EventType = "partition_opened"
+	EventBatchRead       EventType = "batch_read"
+	EventBatchProcessed  EventType = "batch_processed"
+	EventBatchWritten    EventType = "batch_written"
+	// EventPartitionDone fires once every batch of the partition has been written.
+	EventPartitionDone EventType = "partition_done"
+	EventShardDone     EventType = "shard_done"
+	EventRunFinished   EventType = "run_finished"
+)
+
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	Run       string
+	Shard     string
+	Partition string
+	Offset    interface{}
+	Metrics   WorkerMetrics
+	// Duration is the time spent reading, processing or writing a batch, or
+	// the lifetime of the shard or run for the closing events.
+	Duration time.Duration
+	Err      error
+	// Result is only set on EventRunFinished.
+	Result *RunResult
+}
+
+// EventListener is called synchronously from the worker goroutines, so it
+// must be safe for concurrent use and should return quickly.
+type EventListener interface {
+	OnEvent(event Event)
+}
+
+type EventListenerFunc func(event Event)
+
+func (f EventListenerFunc) OnEvent(event Event) {
+	f(event)
+}
+
+type eventListeners []EventListener
+
+func (l eventListeners) OnEvent(event Event) {
+	for _, listener := range l {
+		listener.OnEvent(event)
+	}
+}
+
+func (l eventListeners) emit(event Event) {
+	if len(l) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	l.OnEvent(event)
+}
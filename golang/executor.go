@@ -5,9 +5,155 @@ import (
 	"time"
 
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 )
 
+type executeOptions struct {
+	readParallelism  int
+	writeParallelism int
+	readBufferSize   int
+	readBatchSize    int
+	logger           *zap.Logger
+	progressHooks    []ProgressHook
+
+	checkpoints CheckpointStore
+	deadLetters DeadLetterWriterFactory
+	runId       string
+	budget      *ErrorBudget
+	shutdown    time.Duration
+	onFailure   FailurePolicy
+
+	readTimeout    time.Duration
+	processTimeout time.Duration
+
+	listeners eventListeners
+}
+
+type ExecuteOption func(*executeOptions)
+
+func newExecuteOptions(opts []ExecuteOption) *executeOptions {
+	options := &executeOptions{
+		readParallelism:  1,
+		writeParallelism: 1,
+		readBufferSize:   100,
+		readBatchSize:    100,
+		logger:           zap.NewNop(),
+		shutdown:         30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithReadParallelism sets how many goroutines read the partitions of each shard.
+func WithReadParallelism(parallelism int) ExecuteOption {
+	return func(o *executeOptions) {
+		o.readParallelism = parallelism
+	}
+}
+
+// WithWriteParallelism sets how many producers, each with its own sink, process each shard.
+func WithWriteParallelism(parallelism int) ExecuteOption {
+	return func(o *executeOptions) {
+		o.writeParallelism = parallelism
+	}
+}
+
+// WithReadBufferSize sets how many read batches each shard buffers for its producers.
+func WithReadBufferSize(size int) ExecuteOption {
+	return func(o *executeOptions) {
+		o.readBufferSize = size
+	}
+}
+
+// WithReadBatchSize sets how many records each NextBatch call asks for.
+func WithReadBatchSize(size int) ExecuteOption {
+	return func(o *executeOptions) {
+		o.readBatchSize = size
+	}
+}
+
+func WithLogger(logger *zap.Logger) ExecuteOption {
+	return func(o *executeOptions) {
+		o.logger = logger
+	}
+}
+
+type ProgressHook func(stage Stage, progress []ShardMetrics)
+
+// WithProgressHook is called with the busiest shards every time read or write progress is reported.
+func WithProgressHook(hook ProgressHook) ExecuteOption {
+	return func(o *executeOptions) {
+		o.progressHooks = append(o.progressHooks, hook)
+	}
+}
+
+// WithCheckpointStore records the offset of every written batch in store and
+// resumes partitions from it, skipping those already committed as done.
+func WithCheckpointStore(store CheckpointStore) ExecuteOption {
+	return func(o *executeOptions) {
+		o.checkpoints = store
+	}
+}
+
+// WithDeadLetters writes failed records, with their input and origin, to a
+// dead-letter writer per producer instead of the regular sink.
+func WithDeadLetters(factory DeadLetterWriterFactory) ExecuteOption {
+	return func(o *executeOptions) {
+		o.deadLetters = factory
+	}
+}
+
+// WithRunId tags the dead letters of this run so a later replay can be linked back to it.
+func WithRunId(runId string) ExecuteOption {
+	return func(o *executeOptions) {
+		o.runId = runId
+	}
+}
+
+// WithErrorBudget evaluates budget against the written metrics of every shard,
+// cancelling the run or pausing reads when it is exceeded.
+func WithErrorBudget(budget *ErrorBudget) ExecuteOption {
+	return func(o *executeOptions) {
+		o.budget = budget
+	}
+}
+
+// WithShutdownTimeout bounds how long producers keep draining buffered batches
+// once the run context is cancelled.
+func WithShutdownTimeout(timeout time.Duration) ExecuteOption {
+	return func(o *executeOptions) {
+		o.shutdown = timeout
+	}
+}
+
+// WithFailurePolicy chooses between cancelling the whole run on the first
+// failure (the default) and isolating failed partitions and shards.
+func WithFailurePolicy(policy FailurePolicy) ExecuteOption {
+	return func(o *executeOptions) {
+		o.onFailure = policy
+	}
+}
+
+// WithCallTimeouts gives every partition read and every processed batch its own deadline.
+func WithCallTimeouts(read, process time.Duration) ExecuteOption {
+	return func(o *executeOptions) {
+		o.readTimeout = read
+		o.processTimeout = process
+	}
+}
+
+// WithEventListener is notified of shard, partition and batch lifecycle events.
+func WithEventListener(listener EventListener) ExecuteOption {
+	return func(o *executeOptions) {
+		o.listeners = append(o.listeners, listener)
+	}
+}
+
+type replayedSource interface {
+	ReplayOf() string
+}
+
 func ExecuteAll[T any](
 	ctx context.Context,
 	source ElementSource[T],
@@ -18,58 +164,42 @@ func ExecuteAll[T any](
 	processor ElementProcessor[T],
 	sinkFactory ElementWriterFactory,
 	logger *zap.Logger,
+	opts ...ExecuteOption,
 ) error {
-	shards, err := source.Shards()
-	if err != nil {
-		return err
-	}
-	logger.Info("Processing shards", zap.Int("count", len(shards)))
-	readProgressUpdater, writeProgressUpdater := buildProgressUpdaters(len(shards)*readParallelismPerShard, len(shards)*writeParallelismPerShard)
-
-	var tasks errgroup.Group
-	for _, shard := range shards {
-		l := logger.With(zap.String("shard", shard.Id()))
-		worker := NewShardWorker[T](shard.Id(), readBufferSize, l)
-		tasks.Go(func() error {
-			err := worker.Consume(ctx, shard, readParallelismPerShard, readRecordsBatchSize, func(metrics WorkerMetrics) {
-				readProgressUpdater.Updates <- map[string]WorkerMetrics{shard.Id(): metrics}
-			}, 0)
-			if err != nil {
-				l.Error("Error consuming shard", zap.Error(err))
-				return err
-			}
-
-			return nil
-		})
-		tasks.Go(func() error {
-			err := worker.Produce(ctx, processor, sinkFactory, writeParallelismPerShard, func(metrics WorkerMetrics) {
-				writeProgressUpdater.Updates <- map[string]WorkerMetrics{shard.Id(): metrics}
-			})
-			if err != nil {
-				l.Error("Error producing shard", zap.Error(err))
-				return err
-			}
-			return nil
-		})
-	}
-	var progressUpdatesTasks errgroup.Group
-	readProgressUpdater.Run(&progressUpdatesTasks, 5, func(diff []ShardMetrics) {
-		logger.Info("Read progress", zap.Any("progress", diff))
-	})
-	writeProgressUpdater.Run(&progressUpdatesTasks, 5, func(diff []ShardMetrics) {
-		logger.Info("Write progress changes", zap.Any("progress", diff))
-	})
-
-	err = tasks.Wait()
-	logger.Info("All shards processed", zap.Error(err))
-	readProgressUpdater.Close()
-	writeProgressUpdater.Close()
-	_ = progressUpdatesTasks.Wait()
-	logger.Info(" Reads Stats", zap.Any("stats", readProgressUpdater.Stats()))
-	logger.Info(" Write Stats", zap.Any("stats", writeProgressUpdater.Stats()))
+	opts = append(opts,
+		WithReadParallelism(readParallelismPerShard),
+		WithWriteParallelism(writeParallelismPerShard),
+		WithReadBufferSize(readBufferSize),
+		WithReadBatchSize(readRecordsBatchSize),
+		WithLogger(logger),
+	)
+	_, err := NewPipeline(source, processor, sinkFactory, opts...).Run(ctx)
 	return err
 }
 
+// drainOnCancel returns the context producers run under: it outlives ctx by at
+// most timeout so batches already read can still be processed and written.
+func drainOnCancel(ctx context.Context, timeout time.Duration, logger *zap.Logger) (context.Context, context.CancelFunc) {
+	writeCtx, stopWriting := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-writeCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		logger.Warn("Shutdown requested, draining buffered batches", zap.Duration("timeout", timeout), zap.Error(context.Cause(ctx)))
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-writeCtx.Done():
+		case <-timer.C:
+			logger.Error("Shutdown timeout reached, abandoning buffered batches")
+			stopWriting()
+		}
+	}()
+	return writeCtx, stopWriting
+}
+
 func buildProgressUpdaters(readBuffer, writeBuffer int) (*ProgressUpdater, *ProgressUpdater) {
 	readUpdater := NewProgressUpdater(2*time.Second, readBuffer)
 	writeUpdater := NewProgressUpdater(2*time.Second, writeBuffer)
@@ -0,0 +1,84 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type failingPartition struct {
+	id string
+}
+
+func (f *failingPartition) Id() string {
+	return f.id
+}
+
+func (f *failingPartition) Done() bool {
+	return false
+}
+
+func (f *failingPartition) NextBatch(resource NoResource, batchSize int) ([]*int, interface{}, error) {
+	return nil, nil, errors.New("table is gone")
+}
+
+func (f *failingPartition) Close() error {
+	return nil
+}
+
+type staticSource struct {
+	shards []ElementShard[int]
+}
+
+func (s *staticSource) Id() string {
+	return "static"
+}
+
+func (s *staticSource) Shards() ([]ElementShard[int], error) {
+	return s.shards, nil
+}
+
+func newFailingSource(t *testing.T) ElementSource[int] {
+	var data []*int
+	for i := range 20 {
+		data = append(data, &i)
+	}
+	healthy, err := NewFilesShard[int]("healthy", []ElementPartition[int, NoResource]{&SlicePartition[int]{id: "healthy.table", data: data}})
+	assert.NoError(t, err)
+	broken, err := NewFilesShard[int]("broken", []ElementPartition[int, NoResource]{
+		&failingPartition{id: "broken.table"},
+		&SlicePartition[int]{id: "broken.other", data: data[:5]},
+	})
+	assert.NoError(t, err)
+	return &staticSource{shards: []ElementShard[int]{healthy, broken}}
+}
+
+func TestExecuteAll(t *testing.T) {
+	t.Run("TestContinueOnFailureReportsPartitions", func(t *testing.T) {
+		writer := newMemoryWriter()
+		err := ExecuteAll[int](context.Background(), newFailingSource(t), 1, 2, 10, 3, passThrough[int]{},
+			func(string) (ElementWriter, error) { return writer, nil }, zap.NewNop(),
+			WithFailurePolicy(ContinueOnFailure))
+
+		var failures *RunFailures
+		assert.True(t, errors.As(err, &failures))
+		assert.Len(t, failures.Failures, 1)
+		assert.Equal(t, "broken", failures.Failures[0].Shard)
+		assert.Equal(t, "broken.table", failures.Failures[0].Partition)
+		assert.Equal(t, StageRead, failures.Failures[0].Stage)
+		assert.Len(t, writer.records, 20)
+	})
+
+	t.Run("TestFailFastReturnsFirstError", func(t *testing.T) {
+		writer := newMemoryWriter()
+		err := ExecuteAll[int](context.Background(), newFailingSource(t), 1, 2, 10, 3, passThrough[int]{},
+			func(string) (ElementWriter, error) { return writer, nil }, zap.NewNop())
+
+		assert.EqualError(t, err, "table is gone")
+		var failures *RunFailures
+		assert.False(t, errors.As(err, &failures))
+	})
+}
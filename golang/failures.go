@@ -0,0 +1,97 @@
+package etl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type FailurePolicy int
+
+const (
+	// FailFast cancels every shard as soon as one of them fails.
+	FailFast FailurePolicy = iota
+	// ContinueOnFailure marks the failing partition or shard as failed, keeps
+	// processing the rest and reports all failures at the end of the run.
+	ContinueOnFailure
+)
+
+type Stage string
+
+const (
+	StageRead  Stage = "read"
+	StageWrite Stage = "write"
+)
+
+type PartitionFailure struct {
+	Shard string
+	// Partition is empty when the whole shard failed.
+	Partition string
+	Stage     Stage
+	Err       error
+}
+
+func (f PartitionFailure) String() string {
+	if f.Partition == "" {
+		return fmt.Sprintf("shard %s failed to %s: %v", f.Shard, f.Stage, f.Err)
+	}
+	return fmt.Sprintf("partition %s of shard %s failed to %s: %v", f.Partition, f.Shard, f.Stage, f.Err)
+}
+
+type RunFailures struct {
+	Failures []PartitionFailure
+}
+
+func (e *RunFailures) Error() string {
+	messages := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		messages = append(messages, failure.String())
+	}
+	return fmt.Sprintf("%d failures: %s", len(e.Failures), strings.Join(messages, "; "))
+}
+
+func (e *RunFailures) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure.Err)
+	}
+	return errs
+}
+
+// FailureReport collects failures from concurrent workers; the zero value is ready to use.
+type FailureReport struct {
+	mu       sync.Mutex
+	failures []PartitionFailure
+}
+
+func (r *FailureReport) Add(failure PartitionFailure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, failure)
+}
+
+func (r *FailureReport) Failures() []PartitionFailure {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]PartitionFailure(nil), r.failures...)
+}
+
+// Err returns a *RunFailures holding every failure, or nil when there were none.
+func (r *FailureReport) Err() error {
+	failures := r.Failures()
+	if len(failures) == 0 {
+		return nil
+	}
+	return &RunFailures{Failures: failures}
+}
+
+func (r *FailureReport) shardErr(shard string) error {
+	var errs []error
+	for _, failure := range r.Failures() {
+		if failure.Shard == shard {
+			errs = append(errs, failure.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -3,6 +3,9 @@ package etl
 import (
 	"bufio"
 	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,10 +13,10 @@ import (
 
 type filesShard[T any] struct {
 	shard      string
-	partitions []ElementPartition[T]
+	partitions []ElementPartition[T, NoResource]
 }
 
-func NewFilesShard[T any](shard string, partitions []ElementPartition[T]) (ElementShard[T], error) {
+func NewFilesShard[T any](shard string, partitions []ElementPartition[T, NoResource]) (ElementShard[T], error) {
 	return &filesShard[T]{
 		shard:      shard,
 		partitions: partitions,
@@ -24,14 +27,18 @@ func (s *filesShard[T]) Id() string {
 	return s.shard
 }
 
-func (s *filesShard[T]) NewResource() (Closeable, error) {
+func (s *filesShard[T]) NewResource() (*Lease[NoResource], error) {
 	return nil, nil
 }
 
-func (s *filesShard[T]) Partitions() ([]ElementPartition[T], error) {
+func (s *filesShard[T]) Partitions() ([]ElementPartition[T, NoResource], error) {
 	return s.partitions, nil
 }
 
+func (s *filesShard[T]) ConsumeWith(ctx context.Context, worker *ShardWorker[T], readParallelism int, readBatchSize int, notifyUpdateTo func(WorkerMetrics), maxBatchesPerChunk int) error {
+	return ConsumeShard[T, NoResource](ctx, worker, s, readParallelism, readBatchSize, notifyUpdateTo, maxBatchesPerChunk)
+}
+
 type directorySource[T any] struct {
 	id        string
 	directory string
@@ -52,7 +59,7 @@ func NewDirectorySourceSingleShard[T any](directory string, pattern string, deco
 	if err != nil {
 		return nil, err
 	}
-	var partitions []ElementPartition[T]
+	var partitions []ElementPartition[T, NoResource]
 	for _, file := range files {
 		partition, err := NewFileElementReaderAutoCompressed[T](file, decoder)
 		if err != nil {
@@ -69,7 +76,7 @@ func NewDirectorySourceSingleShard[T any](directory string, pattern string, deco
 }
 
 func NewDirectorySource[T any](directory string, decoder func(data []byte) (*T, error)) (ElementSource[T], error) {
-	filesPerShard := make(map[string][]ElementPartition[T])
+	filesPerShard := make(map[string][]ElementPartition[T, NoResource])
 	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -78,7 +85,7 @@ func NewDirectorySource[T any](directory string, decoder func(data []byte) (*T,
 			if strings.HasSuffix(info.Name(), ".gz") {
 				shard := strings.Split(info.Name(), "_")[0]
 				if _, ok := filesPerShard[shard]; !ok {
-					filesPerShard[shard] = make([]ElementPartition[T], 0)
+					filesPerShard[shard] = make([]ElementPartition[T, NoResource], 0)
 				}
 				reader, err := NewFileElementReaderAutoCompressed[T](path, decoder)
 				if err != nil {
@@ -118,11 +125,11 @@ type FileElementReader[T any] struct {
 	scanner    *bufio.Scanner
 }
 
-func NewFileElementReaderAutoCompressed[T any](path string, decoder func(data []byte) (*T, error)) (ElementPartition[T], error) {
+func NewFileElementReaderAutoCompressed[T any](path string, decoder func(data []byte) (*T, error)) (ElementPartition[T, NoResource], error) {
 	return NewFileElementReader[T](path, strings.HasSuffix(path, ".gz"), decoder)
 }
 
-func NewFileElementReader[T any](path string, compressed bool, decoder func(data []byte) (*T, error)) (ElementPartition[T], error) {
+func NewFileElementReader[T any](path string, compressed bool, decoder func(data []byte) (*T, error)) (ElementPartition[T, NoResource], error) {
 
 	var (
 		file      *os.File
@@ -168,10 +175,17 @@ func (r *FileElementReader[T]) Done() bool {
 	return r.isDone
 }
 
-func (r *FileElementReader[T]) NextBatch(resource interface{}, batchSize int) ([]*T, interface{}, error) {
+func (r *FileElementReader[T]) NextBatch(resource NoResource, batchSize int) ([]*T, interface{}, error) {
+	return r.NextBatchContext(context.Background(), resource, batchSize)
+}
+
+func (r *FileElementReader[T]) NextBatchContext(ctx context.Context, resource NoResource, batchSize int) ([]*T, interface{}, error) {
 	if r.isDone {
 		return nil, r.offset, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, r.offset, err
+	}
 	batch := make([]*T, 0, batchSize)
 	for i := 0; i < batchSize; i++ {
 		if r.scanner.Scan() {
@@ -194,6 +208,23 @@ func (r *FileElementReader[T]) NextBatch(resource interface{}, batchSize int) ([
 	return batch, r.offset, nil
 }
 
+func (r *FileElementReader[T]) Resume(offset json.RawMessage) error {
+	var lines int
+	if err := json.Unmarshal(offset, &lines); err != nil {
+		return err
+	}
+	for r.offset < lines && r.scanner.Scan() {
+		r.offset++
+	}
+	if err := r.scanner.Err(); err != nil {
+		return err
+	}
+	if r.offset < lines {
+		return fmt.Errorf("file %s has %d lines, checkpoint is at line %d", r.path, r.offset, lines)
+	}
+	return nil
+}
+
 func (r *FileElementReader[T]) Close() error {
 	if r.gzip != nil {
 		_ = r.gzip.Close()
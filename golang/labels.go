@@ -0,0 +1,91 @@
+package etl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Labels are values attached to records by their source, such as the named
+// groups captured from a database name. Integers are kept as int64 and
+// everything else as strings.
+type Labels map[string]any
+
+// Labeled is implemented by records carrying labels; the labels of the first
+// record of a batch are written next to every output of the batch.
+type Labeled interface {
+	RecordLabels() Labels
+}
+
+// UnmarshalJSON keeps integers as int64, so labels read back from dead
+// letters or outputs are the labels that were written.
+func (l *Labels) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var labels map[string]any
+	if err := decoder.Decode(&labels); err != nil {
+		return err
+	}
+	for name, value := range labels {
+		if number, ok := value.(json.Number); ok {
+			if integer, err := number.Int64(); err == nil {
+				labels[name] = integer
+			}
+		}
+	}
+	*l = labels
+	return nil
+}
+
+func (l Labels) String(name string) string {
+	switch value := l[name].(type) {
+	case nil:
+		return ""
+	case float64:
+		// as decoded by encoding/json, which would print 1e+07
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (l Labels) Int(name string) (int, error) {
+	switch value := l[name].(type) {
+	case int64:
+		return int(value), nil
+	case nil:
+		return 0, fmt.Errorf("label %s is not set", name)
+	default:
+		return strconv.Atoi(l.String(name))
+	}
+}
+
+// captureLabels returns the named groups of pattern matched in name.
+func captureLabels(pattern *regexp.Regexp, name string) Labels {
+	match := pattern.FindStringSubmatch(name)
+	if match == nil {
+		return nil
+	}
+	var labels Labels
+	for i, group := range pattern.SubexpNames() {
+		if group == "" || i >= len(match) {
+			continue
+		}
+		if labels == nil {
+			labels = make(Labels)
+		}
+		labels[group] = labelValue(match[i])
+	}
+	return labels
+}
+
+// labelValue keeps values with leading zeros as strings, so they are written
+// back exactly as they were captured.
+func labelValue(value string) any {
+	if number, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(number, 10) == value {
+		return number
+	}
+	return value
+}
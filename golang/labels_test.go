@@ -0,0 +1,76 @@
+package etl
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabels(t *testing.T) {
+	t.Run("TestCaptureNamedGroups", func(t *testing.T) {
+		pattern := regexp.MustCompile(`production_env(?P<env_id>\d+)(?:_(?P<region>\w+))?`)
+		labels := captureLabels(pattern, "production_env39_eu")
+		assert.Equal(t, Labels{"env_id": int64(39), "region": "eu"}, labels)
+		env, err := labels.Int("env_id")
+		assert.NoError(t, err)
+		assert.Equal(t, 39, env)
+		assert.Equal(t, "39", labels.String("env_id"))
+
+		assert.Nil(t, captureLabels(regexp.MustCompile("production_env*"), "production_env39"))
+		_, err = Labels(nil).Int("env_id")
+		assert.Error(t, err)
+	})
+
+	t.Run("TestLeadingZerosStayStrings", func(t *testing.T) {
+		labels := captureLabels(regexp.MustCompile(`env(?P<env_id>\d+)`), "env007")
+		assert.Equal(t, "007", labels["env_id"])
+		env, err := labels.Int("env_id")
+		assert.NoError(t, err)
+		assert.Equal(t, 7, env)
+	})
+
+	t.Run("TestOutputsInheritBatchLabels", func(t *testing.T) {
+		records := []*DBRecord[int]{{Labels: Labels{"env_id": int64(1)}}}
+		assert.Equal(t, Labels{"env_id": int64(1)}, batchLabels(records))
+		assert.Nil(t, batchLabels([]*int{new(int)}))
+	})
+	t.Run("TestLabelsSurviveReplay", func(t *testing.T) {
+		directory := filepath.Join(t.TempDir(), "run-1", "dead_letters")
+		writer, err := NewFSDeadLetterFactory(directory)("producer_0")
+		assert.NoError(t, err)
+		record := &DBRecord[replayRecord]{Id: int64(1), Record: &replayRecord{Name: "a"}, Labels: Labels{"env_id": int64(12345678), "region": "eu"}}
+		batch := &PartitionRecordBatch[DBRecord[replayRecord]]{Shard: "shard", Partition: "db.t", Records: []*DBRecord[replayRecord]{record}}
+		assert.NoError(t, writer.Write(NewDeadLetter(batch, record.Id, record, errors.New("render failed"))))
+		assert.NoError(t, writer.Close())
+
+		source, err := NewReplaySource[DBRecord[replayRecord]](directory, JSON_DECODER[DBRecord[replayRecord]])
+		assert.NoError(t, err)
+		shards, err := source.Shards()
+		assert.NoError(t, err)
+		partitions, err := shards[0].(ResourceShard[DBRecord[replayRecord], NoResource]).Partitions()
+		assert.NoError(t, err)
+		records, _, err := partitions[0].NextBatch(NoResource{}, 10)
+		assert.NoError(t, err)
+		assert.Len(t, records, 1)
+		labels := records[0].Labels
+		assert.Equal(t, record.Labels, labels)
+		assert.Equal(t, "12345678", labels.String("env_id"))
+		env, err := labels.Int("env_id")
+		assert.NoError(t, err)
+		assert.Equal(t, 12345678, env)
+	})
+
+	t.Run("TestDecodedNumbersKeepTheirDigits", func(t *testing.T) {
+		labels := Labels{"env_id": float64(12345678), "ratio": 0.5}
+		assert.Equal(t, "12345678", labels.String("env_id"))
+		env, err := labels.Int("env_id")
+		assert.NoError(t, err)
+		assert.Equal(t, 12345678, env)
+		_, err = labels.Int("ratio")
+		assert.Error(t, err)
+		assert.Equal(t, "", labels.String("missing"))
+	})
+}
@@ -0,0 +1,283 @@
+package etl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// WithColumns restricts the columns read by NewDynamicMySQLSource to those
+// whose name fully matches one of the patterns. Key columns are always read.
+func WithColumns(patterns ...string) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.columns = append(o.columns, patterns...)
+	}
+}
+
+// NewDynamicMySQLSource reads tables without a Go struct describing them: the
+// columns and the primary key of every table are discovered from
+// information_schema and each row becomes a map from column name to value.
+func NewDynamicMySQLSource(hosts []string, user, dbMatching, table string, opts ...MySQLOption) (ElementSource[DBRecord[map[string]any]], error) {
+	return newMySQLSource(hosts, user, dbMatching, matchingTables(table, dynamicTableReaders), opts)
+}
+
+func NewDynamicMySQLShard(shard, host, user, dbMatching, table string, opts ...MySQLOption) (ElementShard[DBRecord[map[string]any]], error) {
+	return newMySQLShard(shard, host, user, dbMatching, matchingTables(table, dynamicTableReaders), opts)
+}
+
+func dynamicTableReaders(ctx context.Context, conn *sqlx.DB, database, table string, options *mysqlOptions) ([]ElementPartition[DBRecord[map[string]any], *sqlx.DB], error) {
+	columns, err := describeTable(ctx, conn, database, table)
+	if err != nil {
+		return nil, err
+	}
+	projection, selected, err := dynamicProjection(database, table, columns, options.columns)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newMySqlTableElementReader(conn, database, table, tableFrom(database, table), projection, scanMap(projection, selected))
+	if err != nil {
+		return nil, err
+	}
+	return prepareTableReader(ctx, conn, reader, options)
+}
+
+type columnInfo struct {
+	Name       string `db:"name"`
+	DataType   string `db:"data_type"`
+	ColumnType string `db:"column_type"`
+	Key        bool   `db:"is_key"`
+	Nullable   bool   `db:"nullable"`
+}
+
+// describeTable lists the primary key columns of a table in key order,
+// followed by the other columns in table order.
+func describeTable(ctx context.Context, conn *sqlx.DB, database, table string) ([]columnInfo, error) {
+	var columns []columnInfo
+	err := conn.SelectContext(ctx, &columns, `
+		SELECT c.COLUMN_NAME AS name, c.DATA_TYPE AS data_type, c.COLUMN_TYPE AS column_type, k.ORDINAL_POSITION IS NOT NULL AS is_key,
+			c.IS_NULLABLE = 'YES' AS nullable
+		FROM information_schema.COLUMNS c
+		LEFT JOIN information_schema.KEY_COLUMN_USAGE k
+			ON k.TABLE_SCHEMA = c.TABLE_SCHEMA AND k.TABLE_NAME = c.TABLE_NAME
+			AND k.COLUMN_NAME = c.COLUMN_NAME AND k.CONSTRAINT_NAME = 'PRIMARY'
+		WHERE c.TABLE_SCHEMA = ? AND c.TABLE_NAME = ?
+		ORDER BY k.ORDINAL_POSITION IS NULL, k.ORDINAL_POSITION, c.ORDINAL_POSITION`, database, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s.%s does not exist", database, table)
+	}
+	return columns, nil
+}
+
+func dynamicProjection(database, table string, columns []columnInfo, patterns []string) (*sqlProjection, []columnInfo, error) {
+	var matchers []*regexp.Regexp
+	for _, pattern := range patterns {
+		matcher, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	matches := func(name string) bool {
+		if len(matchers) == 0 {
+			return true
+		}
+		for _, matcher := range matchers {
+			if matcher.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	projection := &sqlProjection{}
+	var selected []columnInfo
+	for _, column := range columns {
+		if !column.Key && !matches(column.Name) {
+			continue
+		}
+		selected = append(selected, column)
+		projection.fields = append(projection.fields, column.Name)
+		if column.Key {
+			projection.pkColumns = append(projection.pkColumns, column.Name)
+			projection.pkTypes = append(projection.pkTypes, column.keyType())
+		}
+	}
+	if len(projection.pkColumns) == 0 {
+		return nil, nil, fmt.Errorf("table %s.%s has no primary key", database, table)
+	}
+	return projection, selected, nil
+}
+
+func scanMap(projection *sqlProjection, columns []columnInfo) rowScanner[map[string]any] {
+	return func(rows *sqlx.Rows) (*map[string]any, []interface{}, error) {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, err
+		}
+		record := make(map[string]any, len(columns))
+		key := make([]interface{}, 0, len(projection.pkColumns))
+		for i, column := range columns {
+			value, err := column.convert(values[i])
+			if err != nil {
+				return nil, nil, fmt.Errorf("column %s: %w", column.Name, err)
+			}
+			record[column.Name] = value
+			if column.Key {
+				keyValue, err := column.key(values[i])
+				if err != nil {
+					return nil, nil, fmt.Errorf("column %s: %w", column.Name, err)
+				}
+				key = append(key, keyValue)
+			}
+		}
+		return &record, key, nil
+	}
+}
+
+func (c columnInfo) unsigned() bool {
+	return strings.Contains(c.ColumnType, "unsigned")
+}
+
+func (c columnInfo) isInteger() bool {
+	switch c.DataType {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "year":
+		return true
+	}
+	return false
+}
+
+func (c columnInfo) isBinary() bool {
+	switch c.DataType {
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return true
+	}
+	return false
+}
+
+// keyType is the type key values of this column are kept in, so they can be
+// bound back into queries and round trip through checkpoints.
+func (c columnInfo) keyType() reflect.Type {
+	switch {
+	case c.isInteger() && c.unsigned():
+		return reflect.TypeOf(uint64(0))
+	case c.isInteger():
+		return reflect.TypeOf(int64(0))
+	case c.isBinary():
+		return reflect.TypeOf([]byte(nil))
+	default:
+		return reflect.TypeOf("")
+	}
+}
+
+func (c columnInfo) key(raw interface{}) (interface{}, error) {
+	switch {
+	case c.isInteger():
+		return c.integer(raw)
+	case c.isBinary():
+		return raw, nil
+	default:
+		return text(raw), nil
+	}
+}
+
+// convert turns a value as returned by the driver into one that encodes well:
+// integers and floats stay numbers, decimals become strings so they keep
+// their precision, datetimes become RFC3339, binary(16) becomes a UUID and
+// other binary values base64.
+func (c columnInfo) convert(raw interface{}) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	switch {
+	case c.isInteger():
+		return c.integer(raw)
+	case c.isBinary():
+		data, _ := raw.([]byte)
+		if c.ColumnType == "binary(16)" && len(data) == 16 {
+			id, err := uuid.FromBytes(data)
+			return id.String(), err
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+
+	switch c.DataType {
+	case "float", "double":
+		if value, ok := raw.(float64); ok {
+			return value, nil
+		}
+		return strconv.ParseFloat(text(raw), 64)
+	case "bit":
+		var value uint64
+		data, _ := raw.([]byte)
+		for _, b := range data {
+			value = value<<8 | uint64(b)
+		}
+		return value, nil
+	case "datetime", "timestamp":
+		if value, ok := raw.(time.Time); ok {
+			return value.Format(time.RFC3339Nano), nil
+		}
+		if strings.HasPrefix(text(raw), "0000-00-00") {
+			return nil, nil
+		}
+		value, err := time.ParseInLocation(time.DateTime, text(raw), time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		return value.Format(time.RFC3339Nano), nil
+	case "date":
+		if value, ok := raw.(time.Time); ok {
+			return value.Format(time.DateOnly), nil
+		}
+		return text(raw), nil
+	case "json":
+		return json.RawMessage(text(raw)), nil
+	default:
+		return text(raw), nil
+	}
+}
+
+func (c columnInfo) integer(raw interface{}) (interface{}, error) {
+	switch value := raw.(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		if c.unsigned() {
+			return uint64(value), nil
+		}
+		return value, nil
+	case uint64:
+		return value, nil
+	}
+	if c.unsigned() {
+		return strconv.ParseUint(text(raw), 10, 64)
+	}
+	return strconv.ParseInt(text(raw), 10, 64)
+}
+
+func text(raw interface{}) string {
+	switch value := raw.(type) {
+	case []byte:
+		return string(value)
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
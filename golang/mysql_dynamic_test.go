@@ -0,0 +1,59 @@
+package etl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamicMySQL(t *testing.T) {
+	columns := []columnInfo{
+		{Name: "customer_id", DataType: "int", ColumnType: "int unsigned", Key: true},
+		{Name: "uuid", DataType: "binary", ColumnType: "binary(16)", Key: true},
+		{Name: "amount", DataType: "decimal", ColumnType: "decimal(10,2)"},
+		{Name: "created_at", DataType: "datetime", ColumnType: "datetime(6)"},
+		{Name: "payload", DataType: "json", ColumnType: "json"},
+		{Name: "secret", DataType: "varchar", ColumnType: "varchar(255)"},
+	}
+
+	t.Run("TestProjectionKeepsKeyColumns", func(t *testing.T) {
+		projection, selected, err := dynamicProjection("db", "t", columns, []string{"amount", "created_.*"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"customer_id", "uuid"}, projection.pkColumns)
+		assert.Equal(t, []string{"customer_id", "uuid", "amount", "created_at"}, projection.fields)
+		assert.Len(t, selected, 4)
+
+		_, _, err = dynamicProjection("db", "t", columns[2:], nil)
+		assert.Error(t, err, "a table without primary key cannot be paged")
+	})
+
+	t.Run("TestConvertsDriverValues", func(t *testing.T) {
+		id := []byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+		raw := map[string]interface{}{
+			"customer_id": []byte("42"),
+			"uuid":        id,
+			"amount":      []byte("10.50"),
+			"created_at":  []byte("2024-11-05 10:11:12.5"),
+			"payload":     []byte(`{"a":1}`),
+			"secret":      nil,
+		}
+		expected := map[string]interface{}{
+			"customer_id": uint64(42),
+			"uuid":        "123e4567-e89b-12d3-a456-426614174000",
+			"amount":      "10.50",
+			"created_at":  "2024-11-05T10:11:12.5Z",
+			"payload":     json.RawMessage(`{"a":1}`),
+			"secret":      nil,
+		}
+		for _, column := range columns {
+			value, err := column.convert(raw[column.Name])
+			assert.NoError(t, err)
+			assert.Equal(t, expected[column.Name], value, column.Name)
+		}
+
+		key, err := columns[1].key(id)
+		assert.NoError(t, err)
+		assert.Equal(t, id, key, "binary keys stay binary so they can be bound back")
+	})
+}
@@ -0,0 +1,154 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeResult is what fakeServer answers to a statement: the rows of a query,
+// nothing for other statements, or an error.
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error
+}
+
+// fakeServer answers the statements of every connection opened on it with
+// respond, recording them in order.
+type fakeServer struct {
+	respond func(query string, args []driver.NamedValue) fakeResult
+
+	mu         sync.Mutex
+	statements []string
+}
+
+func (s *fakeServer) open(maxOpen int) *sqlx.DB {
+	db := sqlx.NewDb(sql.OpenDB(fakeConnector{s}), "mysql")
+	db.SetMaxOpenConns(maxOpen)
+	return db
+}
+
+func (s *fakeServer) run(query string, args []driver.NamedValue) fakeResult {
+	s.mu.Lock()
+	s.statements = append(s.statements, query)
+	s.mu.Unlock()
+	return s.respond(query, args)
+}
+
+type fakeConnector struct {
+	server *fakeServer
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{server: c.server}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("open through fakeConnector")
+}
+
+type fakeConn struct {
+	server *fakeServer
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := c.server.run(query, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := c.server.run(query, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type fakeRecord struct {
+	Id int64 `db:"id" sql:"pk"`
+}
+
+var fakeLimit = regexp.MustCompile(`LIMIT (\d+)`)
+
+// fakeTableRows answers the keyset queries on a table of ids 1 to n, honouring
+// the key cursor and the limit of the query.
+func fakeTableRows(n int64, query string, args []driver.NamedValue) fakeResult {
+	var after int64
+	if len(args) > 0 {
+		after = args[0].Value.(int64)
+	}
+	limit := 0
+	if match := fakeLimit.FindStringSubmatch(query); match != nil {
+		limit, _ = strconv.Atoi(match[1])
+	}
+	result := fakeResult{columns: []string{"id"}}
+	for id := after + 1; id <= n && (limit == 0 || len(result.rows) < limit); id++ {
+		result.rows = append(result.rows, []driver.Value{id})
+	}
+	return result
+}
+
+// newFakeReader reads table t of database db on db with options.
+func newFakeReader(t *testing.T, db *sqlx.DB, options *mysqlOptions) *MySqlTableElementReader[fakeRecord] {
+	projection := extractPkColumn[fakeRecord]()
+	reader, err := newMySqlTableElementReader(db, "db", "t", tableFrom("db", "t"), projection, scanStruct[fakeRecord](projection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.applyReadOptions(options)
+	reader.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	return reader
+}
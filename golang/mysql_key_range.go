@@ -0,0 +1,237 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type KeySplitMode int
+
+const (
+	// SplitByMinMax interpolates boundaries between the smallest and largest
+	// key; it needs an integer or binary primary key and assumes keys are
+	// spread evenly, which holds for auto-increment ids and time-ordered UUIDs.
+	SplitByMinMax KeySplitMode = iota
+	// SplitByQuantiles samples the key at evenly spaced row offsets. It follows
+	// skewed key distributions but every sample scans up to its offset.
+	SplitByQuantiles
+)
+
+type KeyRangeSplit struct {
+	Partitions int
+	// MinRows leaves tables whose estimated row count is below it unsplit.
+	MinRows int64
+	Mode    KeySplitMode
+	// Checkpoints keeps the boundaries each table was first split on, so that
+	// a resumed run reads the same ranges however the table changed since.
+	// It is the checkpoint store of the run; without one, every run splits
+	// the tables afresh.
+	Checkpoints CheckpointStore
+}
+
+type keyRange struct {
+	lower interface{}
+	upper interface{}
+}
+
+func estimateTableRows(ctx context.Context, conn *sqlx.DB, database, table string) (int64, error) {
+	var rows int64
+	err := conn.GetContext(ctx, &rows,
+		"SELECT COALESCE(TABLE_ROWS, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?",
+		database, table)
+	return rows, err
+}
+
+// splitKeyRange returns contiguous ranges covering the whole table. The first
+// range has no lower bound and the last no upper bound, so rows outside the
+// sampled keys are still read. The boundaries are recorded in the checkpoints
+// of split on the first run, and reused when the run is resumed.
+func splitKeyRange(ctx context.Context, conn *sqlx.DB, shard, database, table, pkColumn string, pkType reflect.Type, split KeyRangeSplit) ([]keyRange, error) {
+	if split.Partitions < 2 {
+		return []keyRange{{}}, nil
+	}
+	if split.Checkpoints == nil {
+		boundaries, err := keyBoundaries(ctx, conn, database, table, pkColumn, pkType, split)
+		return rangesBetween(boundaries), err
+	}
+	partition := keyRangeCheckpoint(database, table)
+	checkpoint, err := split.Checkpoints.Load(shard, partition)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint != nil {
+		boundaries, err := decodeBoundaries(checkpoint.Offset, pkType)
+		if err != nil {
+			return nil, fmt.Errorf("reading the key ranges of %s.%s: %w", database, table, err)
+		}
+		return rangesBetween(boundaries), nil
+	}
+	boundaries, err := keyBoundaries(ctx, conn, database, table, pkColumn, pkType, split)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(boundaries)
+	if err != nil {
+		return nil, err
+	}
+	if err := split.Checkpoints.Commit(PartitionCheckpoint{Shard: shard, Partition: partition, Offset: encoded}); err != nil {
+		return nil, err
+	}
+	return rangesBetween(boundaries), nil
+}
+
+// keyRangeCheckpoint names the checkpoint keeping the boundaries of a table,
+// apart from the checkpoints of its ranges.
+func keyRangeCheckpoint(database, table string) string {
+	return fmt.Sprintf("%s.%s#ranges", database, table)
+}
+
+func keyBoundaries(ctx context.Context, conn *sqlx.DB, database, table, pkColumn string, pkType reflect.Type, split KeyRangeSplit) ([]interface{}, error) {
+	rows, err := estimateTableRows(ctx, conn, database, table)
+	if err != nil {
+		return nil, err
+	}
+	if rows < split.MinRows {
+		return nil, nil
+	}
+	switch {
+	case split.Mode == SplitByMinMax && isIntegerKind(pkType.Kind()):
+		return integerBoundaries(ctx, conn, database, table, pkColumn, pkType, split.Partitions)
+	case split.Mode == SplitByMinMax && pkType == reflect.TypeOf([]byte(nil)):
+		return bytesBoundaries(ctx, conn, database, table, pkColumn, split.Partitions)
+	default:
+		return quantileBoundaries(ctx, conn, database, table, pkColumn, pkType, rows, split.Partitions)
+	}
+}
+
+func decodeBoundaries(encoded json.RawMessage, pkType reflect.Type) ([]interface{}, error) {
+	var values []json.RawMessage
+	if err := json.Unmarshal(encoded, &values); err != nil {
+		return nil, err
+	}
+	boundaries := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		boundary := reflect.New(pkType)
+		if err := json.Unmarshal(value, boundary.Interface()); err != nil {
+			return nil, err
+		}
+		boundaries = append(boundaries, boundary.Elem().Interface())
+	}
+	return boundaries, nil
+}
+
+func rangesBetween(boundaries []interface{}) []keyRange {
+	ranges := make([]keyRange, 0, len(boundaries)+1)
+	var lower interface{}
+	for _, boundary := range boundaries {
+		ranges = append(ranges, keyRange{lower: lower, upper: boundary})
+		lower = boundary
+	}
+	return append(ranges, keyRange{lower: lower})
+}
+
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func selectKeyBounds(ctx context.Context, conn *sqlx.DB, database, table, pkColumn string, lower, upper interface{}) error {
+	query := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", quoteIdentifier(pkColumn), quoteIdentifier(pkColumn), tableFrom(database, table))
+	return conn.QueryRowxContext(ctx, query).Scan(lower, upper)
+}
+
+func integerBoundaries(ctx context.Context, conn *sqlx.DB, database, table, pkColumn string, pkType reflect.Type, partitions int) ([]interface{}, error) {
+	var lo, hi *big.Int
+	if pkType.Kind() >= reflect.Uint && pkType.Kind() <= reflect.Uint64 {
+		var first, last *uint64
+		if err := selectKeyBounds(ctx, conn, database, table, pkColumn, &first, &last); err != nil || first == nil {
+			return nil, err
+		}
+		lo, hi = new(big.Int).SetUint64(*first), new(big.Int).SetUint64(*last)
+	} else {
+		var first, last *int64
+		if err := selectKeyBounds(ctx, conn, database, table, pkColumn, &first, &last); err != nil || first == nil {
+			return nil, err
+		}
+		lo, hi = big.NewInt(*first), big.NewInt(*last)
+	}
+	var boundaries []interface{}
+	for _, point := range interpolate(lo, hi, partitions) {
+		boundary := reflect.New(pkType).Elem()
+		if boundary.CanInt() {
+			boundary.SetInt(point.Int64())
+		} else {
+			boundary.SetUint(point.Uint64())
+		}
+		boundaries = append(boundaries, boundary.Interface())
+	}
+	return boundaries, nil
+}
+
+func bytesBoundaries(ctx context.Context, conn *sqlx.DB, database, table, pkColumn string, partitions int) ([]interface{}, error) {
+	var first, last []byte
+	if err := selectKeyBounds(ctx, conn, database, table, pkColumn, &first, &last); err != nil || first == nil {
+		return nil, err
+	}
+	width := max(len(first), len(last))
+	pad := func(key []byte) *big.Int {
+		padded := make([]byte, width)
+		copy(padded, key)
+		return new(big.Int).SetBytes(padded)
+	}
+	var boundaries []interface{}
+	for _, point := range interpolate(pad(first), pad(last), partitions) {
+		boundaries = append(boundaries, point.FillBytes(make([]byte, width)))
+	}
+	return boundaries, nil
+}
+
+// interpolate returns the distinct points strictly between lo and hi that cut
+// [lo, hi] into parts of equal width.
+func interpolate(lo, hi *big.Int, parts int) []*big.Int {
+	width := new(big.Int).Sub(hi, lo)
+	var points []*big.Int
+	for i := 1; i < parts; i++ {
+		point := new(big.Int).Mul(width, big.NewInt(int64(i)))
+		point.Div(point, big.NewInt(int64(parts)))
+		point.Add(point, lo)
+		if point.Cmp(lo) <= 0 || (len(points) > 0 && point.Cmp(points[len(points)-1]) == 0) {
+			continue
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+func quantileBoundaries(ctx context.Context, conn *sqlx.DB, database, table, pkColumn string, pkType reflect.Type, rows int64, partitions int) ([]interface{}, error) {
+	var boundaries []interface{}
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s LIMIT 1 OFFSET ?", quoteIdentifier(pkColumn), tableFrom(database, table), quoteIdentifier(pkColumn))
+	for i := 1; i < partitions; i++ {
+		boundary := reflect.New(pkType)
+		err := conn.QueryRowxContext(ctx, query, rows*int64(i)/int64(partitions)).Scan(boundary.Interface())
+		if errors.Is(err, sql.ErrNoRows) {
+			// TABLE_ROWS is only an estimate and may overshoot the real count
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := boundary.Elem().Interface()
+		if len(boundaries) > 0 && reflect.DeepEqual(boundaries[len(boundaries)-1], value) {
+			continue
+		}
+		boundaries = append(boundaries, value)
+	}
+	return boundaries, nil
+}
@@ -0,0 +1,107 @@
+package etl
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyRange(t *testing.T) {
+	t.Run("TestInterpolateSplitsEvenly", func(t *testing.T) {
+		points := interpolate(big.NewInt(0), big.NewInt(100), 4)
+		assert.Equal(t, []*big.Int{big.NewInt(25), big.NewInt(50), big.NewInt(75)}, points)
+	})
+
+	t.Run("TestInterpolateDropsDuplicatePoints", func(t *testing.T) {
+		points := interpolate(big.NewInt(10), big.NewInt(12), 8)
+		assert.Equal(t, []*big.Int{big.NewInt(11)}, points)
+		assert.Empty(t, interpolate(big.NewInt(7), big.NewInt(7), 4))
+	})
+
+	t.Run("TestCheckpointsKeepRangeBounds", func(t *testing.T) {
+		server := &fakeServer{respond: func(query string, args []driver.NamedValue) fakeResult {
+			return fakeTableRows(300, query, args)
+		}}
+		db := server.open(1)
+		defer db.Close()
+		rangeReader := func(lower, upper int64) *MySqlTableElementReader[fakeRecord] {
+			reader := newFakeReader(t, db, newMySQLOptions(nil))
+			reader.keyRange = keyRange{lower: lower, upper: upper}
+			reader.rangeId = 2
+			return reader
+		}
+
+		_, offset, err := rangeReader(100, 200).NextBatchContext(context.Background(), db, 5)
+		assert.NoError(t, err)
+		raw, err := json.Marshal(offset)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"key":[105],"range":[100,200]}`, string(raw))
+
+		resumed := rangeReader(100, 200)
+		assert.NoError(t, resumed.Resume(raw))
+		assert.Equal(t, []interface{}{int64(105)}, resumed.lastKey)
+
+		store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.jsonl"))
+		assert.NoError(t, err)
+		defer store.Close()
+		resplit := rangeReader(100, 250)
+		assert.NoError(t, store.Commit(PartitionCheckpoint{Shard: "shard", Partition: resplit.Id(), Offset: raw, Done: true}))
+		_, _, err = restorePartitions(newCheckpointTracker("shard", store), []ElementPartition[DBRecord[fakeRecord], *sqlx.DB]{resplit})
+		assert.ErrorIs(t, err, ErrKeyRangeChanged, "a done range is not skipped once its bounds moved")
+		assert.ErrorIs(t, resplit.Resume(raw), ErrKeyRangeChanged)
+	})
+	t.Run("TestResumedRunKeepsItsRanges", func(t *testing.T) {
+		rows := int64(100)
+		server := &fakeServer{respond: func(query string, args []driver.NamedValue) fakeResult {
+			switch {
+			case strings.Contains(query, "TABLE_ROWS"):
+				return fakeResult{columns: []string{"rows"}, rows: [][]driver.Value{{rows}}}
+			case strings.Contains(query, "MIN("):
+				return fakeResult{columns: []string{"min", "max"}, rows: [][]driver.Value{{int64(1), rows}}}
+			}
+			return fakeTableRows(rows, query, args)
+		}}
+		db := server.open(1)
+		defer db.Close()
+		path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+		store, err := NewFileCheckpointStore(path)
+		assert.NoError(t, err)
+		split := KeyRangeSplit{Partitions: 4, Checkpoints: store}
+		first, err := splitTableReader(context.Background(), db, newFakeReader(t, db, newMySQLOptions(nil)), "shard", split)
+		assert.NoError(t, err)
+		assert.Len(t, first, 4)
+		_, offset, err := first[1].NextBatch(db, 5)
+		assert.NoError(t, err)
+		raw, err := json.Marshal(offset)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Commit(PartitionCheckpoint{Shard: "shard", Partition: first[1].Id(), Offset: raw}))
+		assert.NoError(t, store.Close())
+
+		// the table grew before the run was resumed
+		rows = 1000
+		store, err = NewFileCheckpointStore(path)
+		assert.NoError(t, err)
+		defer store.Close()
+		split.Checkpoints = store
+		resumed, err := splitTableReader(context.Background(), db, newFakeReader(t, db, newMySQLOptions(nil)), "shard", split)
+		assert.NoError(t, err)
+		pending, _, err := restorePartitions(newCheckpointTracker("shard", store), resumed)
+		assert.NoError(t, err)
+		assert.Len(t, pending, 4)
+		last := resumed[3].(*MySqlTableElementReader[fakeRecord])
+		assert.Nil(t, last.keyRange.upper, "rows past the first run's keys are still read")
+
+		split.Checkpoints = nil
+		fresh, err := splitTableReader(context.Background(), db, newFakeReader(t, db, newMySQLOptions(nil)), "shard", split)
+		assert.NoError(t, err)
+		_, _, err = restorePartitions(newCheckpointTracker("shard", store), fresh)
+		assert.ErrorIs(t, err, ErrKeyRangeChanged, "a fresh split follows the table")
+	})
+}
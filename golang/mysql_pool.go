@@ -0,0 +1,225 @@
+package etl
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/semaphore"
+)
+
+// MySQLPool configures the connection pool each shard shares between its
+// chunks. Zero values keep the database/sql defaults.
+type MySQLPool struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+	MaxIdleTime time.Duration
+	// WaitTimeout bounds how long a partition holding a connection of its
+	// own, to stream or read from a snapshot, waits for one before failing
+	// with ErrConnectionStarved rather than stall its chunk silently; a minute
+	// by default.
+	WaitTimeout time.Duration
+}
+
+// ErrConnectionStarved fails a partition that found every connection of the
+// pool held by other partitions for longer than MySQLPool.WaitTimeout.
+var ErrConnectionStarved = errors.New("no connection of the pool was freed")
+
+const defaultConnectionWait = time.Minute
+
+// acquireConn takes a connection out of db for a partition to hold, waiting
+// for one under ctx and at most wait.
+func acquireConn(ctx context.Context, db *sqlx.DB, wait time.Duration) (*sqlx.Conn, error) {
+	if wait <= 0 {
+		wait = defaultConnectionWait
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	conn, err := db.Connx(waitCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w within %s", ErrConnectionStarved, wait)
+	}
+	return conn, err
+}
+
+func WithPool(pool MySQLPool) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.pool = pool
+	}
+}
+
+// ConnectionBudget bounds the MySQL connections open at once by every source
+// sharing it, in total and per host; a limit of 0 is unbounded. Opening a
+// connection waits for the budget, and idle connections count against it
+// until MaxIdleTime closes them. A partition reading from a consistent
+// snapshot holds a connection of its own until it is done, so the budget of
+// a host must allow for them on top of the pools.
+type ConnectionBudget struct {
+	total   *semaphore.Weighted
+	perHost int64
+
+	mu    sync.Mutex
+	hosts map[string]*semaphore.Weighted
+}
+
+func NewConnectionBudget(total, perHost int) *ConnectionBudget {
+	budget := &ConnectionBudget{perHost: int64(perHost), hosts: make(map[string]*semaphore.Weighted)}
+	if total > 0 {
+		budget.total = semaphore.NewWeighted(int64(total))
+	}
+	return budget
+}
+
+// WithConnectionBudget opens every connection of the source within budget.
+func WithConnectionBudget(budget *ConnectionBudget) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.budget = budget
+	}
+}
+
+func (b *ConnectionBudget) host(addr string) *semaphore.Weighted {
+	if b.perHost <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	host, ok := b.hosts[addr]
+	if !ok {
+		host = semaphore.NewWeighted(b.perHost)
+		b.hosts[addr] = host
+	}
+	return host
+}
+
+// acquire takes a connection of addr out of the budget, the host first so
+// connections waiting on a busy host do not hold the run-wide budget.
+func (b *ConnectionBudget) acquire(ctx context.Context, addr string) (func(), error) {
+	host := b.host(addr)
+	if host != nil {
+		if err := host.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+	}
+	if b.total != nil {
+		if err := b.total.Acquire(ctx, 1); err != nil {
+			if host != nil {
+				host.Release(1)
+			}
+			return nil, err
+		}
+	}
+	return func() {
+		if b.total != nil {
+			b.total.Release(1)
+		}
+		if host != nil {
+			host.Release(1)
+		}
+	}, nil
+}
+
+type budgetedConnector struct {
+	driver.Connector
+	budget *ConnectionBudget
+	addr   string
+}
+
+func (c budgetedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	release, err := c.budget.acquire(ctx, c.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &budgetedConn{Conn: conn, release: release}, nil
+}
+
+// budgetedConn gives its connection back to the budget when closed. It
+// forwards the optional interfaces of the driver, which database/sql would
+// otherwise emulate with prepared statements.
+type budgetedConn struct {
+	driver.Conn
+	release func()
+	once    sync.Once
+}
+
+var (
+	_ driver.QueryerContext     = (*budgetedConn)(nil)
+	_ driver.ExecerContext      = (*budgetedConn)(nil)
+	_ driver.ConnPrepareContext = (*budgetedConn)(nil)
+	_ driver.ConnBeginTx        = (*budgetedConn)(nil)
+	_ driver.Pinger             = (*budgetedConn)(nil)
+	_ driver.SessionResetter    = (*budgetedConn)(nil)
+	_ driver.Validator          = (*budgetedConn)(nil)
+	_ driver.NamedValueChecker  = (*budgetedConn)(nil)
+)
+
+func (c *budgetedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+func (c *budgetedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *budgetedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *budgetedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *budgetedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return nil, errors.New("driver does not support BeginTx")
+}
+
+func (c *budgetedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *budgetedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *budgetedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *budgetedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
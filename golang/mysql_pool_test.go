@@ -0,0 +1,35 @@
+package etl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionBudget(t *testing.T) {
+	t.Run("TestWaitsForHostAndTotal", func(t *testing.T) {
+		budget := NewConnectionBudget(3, 2)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		releaseA1, err := budget.acquire(ctx, "a:3306")
+		assert.NoError(t, err)
+		_, err = budget.acquire(ctx, "a:3306")
+		assert.NoError(t, err)
+		_, err = budget.acquire(ctx, "a:3306")
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "host a is at its limit")
+
+		_, err = budget.acquire(context.Background(), "b:3306")
+		assert.NoError(t, err)
+		expired, cancelExpired := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancelExpired()
+		_, err = budget.acquire(expired, "b:3306")
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "the run is at its limit")
+
+		releaseA1()
+		_, err = budget.acquire(context.Background(), "b:3306")
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,66 @@
+package etl
+
+import "time"
+
+type SkippedTable struct {
+	Database string
+	// Table is empty when the whole database was skipped.
+	Table  string
+	Reason string
+}
+
+type HostReport struct {
+	Host string
+	// Err is set when the host could not be read at all, because it was
+	// unreachable or its databases could not be listed.
+	Err        error
+	Partitions []string
+	Skipped    []SkippedTable
+	Duration   time.Duration
+}
+
+// PreflightReport tells, for each host of a MySQL source, which partitions
+// will be read and which databases and tables were skipped and why.
+type PreflightReport struct {
+	Hosts []HostReport
+}
+
+func (r *PreflightReport) Unreachable() []HostReport {
+	var unreachable []HostReport
+	for _, host := range r.Hosts {
+		if host.Err != nil {
+			unreachable = append(unreachable, host)
+		}
+	}
+	return unreachable
+}
+
+func (r *PreflightReport) Skipped() []SkippedTable {
+	var skipped []SkippedTable
+	for _, host := range r.Hosts {
+		skipped = append(skipped, host.Skipped...)
+	}
+	return skipped
+}
+
+// WithPreflightReport fills report while the source checks its hosts.
+func WithPreflightReport(report *PreflightReport) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.preflight = report
+	}
+}
+
+// WithPartialShards builds the source from the hosts that could be read,
+// leaving out the others, rather than failing it.
+func WithPartialShards() MySQLOption {
+	return func(o *mysqlOptions) {
+		o.partialShards = true
+	}
+}
+
+// WithPreflightParallelism bounds how many hosts are checked at once, 16 by default.
+func WithPreflightParallelism(parallelism int) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.preflightParallelism = parallelism
+	}
+}
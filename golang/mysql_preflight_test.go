@@ -0,0 +1,22 @@
+package etl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflight(t *testing.T) {
+	t.Run("TestUnreachableHostsAreReported", func(t *testing.T) {
+		// the port makes the address invalid, so dialing fails without a network
+		hosts := []string{"unreachable-a:1", "unreachable-b:1"}
+		var report PreflightReport
+		_, err := NewMySQLSource[customerRecord](hosts, "root", ".*", "t", WithPreflightReport(&report))
+		assert.Error(t, err)
+		assert.Len(t, report.Unreachable(), 2)
+		assert.Equal(t, "unreachable-a:1", report.Hosts[0].Host)
+
+		_, err = NewMySQLSource[customerRecord](hosts, "root", ".*", "t", WithPartialShards())
+		assert.Error(t, err, "partial shards still need one readable host")
+	})
+}
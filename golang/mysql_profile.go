@@ -0,0 +1,279 @@
+package etl
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap/zapcore"
+)
+
+// Secret is a credential that never prints: fmt, JSON and zap all see it as
+// redacted, only Reveal returns its value.
+type Secret string
+
+const redacted = "[REDACTED]"
+
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return redacted
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// SecretSource resolves a secret when a connection is opened, so rotated
+// secrets are picked up by new connections.
+type SecretSource func() (Secret, error)
+
+func EnvSecret(name string) SecretSource {
+	return func() (Secret, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret %s is not set in the environment", name)
+		}
+		return Secret(value), nil
+	}
+}
+
+// FileSecret reads a secret from a file, either its whole content or, when
+// key is set, the value of a KEY=VALUE line.
+func FileSecret(path, key string) SecretSource {
+	return func() (Secret, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if key == "" {
+			return Secret(strings.TrimSpace(string(data))), nil
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if ok && strings.TrimSpace(name) == key {
+				return Secret(strings.Trim(strings.TrimSpace(value), `"'`)), nil
+			}
+		}
+		return "", fmt.Errorf("secret %s is not in %s", key, path)
+	}
+}
+
+type TLSProfile struct {
+	// CAFile verifies the server against a private CA rather than the system roots.
+	CAFile string
+	// CertFile and KeyFile authenticate the client.
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// ConnectionProfile describes how to connect to the hosts of a MySQL source.
+type ConnectionProfile struct {
+	// User defaults to the user given to the source.
+	User     string
+	Password SecretSource
+	// Users and Passwords override User and Password for the hosts they list.
+	Users     map[string]string
+	Passwords map[string]SecretSource
+	// Port applies to hosts without a port of their own in Ports, 3306 by default.
+	Port  int
+	Ports map[string]int
+	TLS   *TLSProfile
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// Params are DSN parameters such as parseTime or collation; unknown ones
+	// are set as session variables.
+	Params map[string]string
+}
+
+// WithConnectionProfile connects to every host of the source with profile.
+func WithConnectionProfile(profile ConnectionProfile) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.profile = profile
+	}
+}
+
+func (p ConnectionProfile) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("user", p.User)
+	enc.AddBool("password", p.Password != nil)
+	enc.AddInt("port", p.Port)
+	enc.AddBool("tls", p.TLS != nil)
+	enc.AddDuration("dialTimeout", p.DialTimeout)
+	enc.AddDuration("readTimeout", p.ReadTimeout)
+	enc.AddDuration("writeTimeout", p.WriteTimeout)
+	return nil
+}
+
+func (p ConnectionProfile) address(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	port, ok := p.Ports[host]
+	if !ok {
+		port = p.Port
+	}
+	if port == 0 {
+		port = 3306
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// config builds the driver configuration for host without its password,
+// which connections resolve each time, and without ever formatting the
+// password into a DSN.
+func (p ConnectionProfile) config(host, user string) (*mysql.Config, error) {
+	params := url.Values{}
+	for name, value := range p.Params {
+		params.Set(name, value)
+	}
+	config, err := mysql.ParseDSN("/?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN params: %w", err)
+	}
+	config.Net = "tcp"
+	config.Addr = p.address(host)
+	config.User = user
+	if p.User != "" {
+		config.User = p.User
+	}
+	if hostUser, ok := p.Users[host]; ok {
+		config.User = hostUser
+	}
+	if p.DialTimeout > 0 {
+		config.Timeout = p.DialTimeout
+	}
+	config.ReadTimeout = p.ReadTimeout
+	config.WriteTimeout = p.WriteTimeout
+	if p.TLS != nil {
+		if config.TLS, err = p.TLS.config(host); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func (p ConnectionProfile) password(host string) SecretSource {
+	if password, ok := p.Passwords[host]; ok {
+		return password
+	}
+	return p.Password
+}
+
+func (t *TLSProfile) config(host string) (*tls.Config, error) {
+	serverName := t.ServerName
+	if serverName == "" {
+		serverName = host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			serverName = hostname
+		}
+	}
+	config := &tls.Config{ServerName: serverName, InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		ca, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// profileConnector opens every connection with the configuration of its
+// host, built once along with its TLS files, resolving only the password
+// again so rotated secrets are picked up.
+type profileConnector struct {
+	config   *mysql.Config
+	password SecretSource
+}
+
+func newProfileConnector(profile ConnectionProfile, host, user string) (profileConnector, error) {
+	config, err := profile.config(host, user)
+	if err != nil {
+		return profileConnector{}, err
+	}
+	return profileConnector{config: config, password: profile.password(host)}, nil
+}
+
+func (c profileConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	config := c.config.Clone()
+	if c.password != nil {
+		password, err := c.password()
+		if err != nil {
+			return nil, err
+		}
+		config.Passwd = password.Reveal()
+	}
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c profileConnector) Driver() driver.Driver {
+	return &mysql.MySQLDriver{}
+}
+
+// connectMySQL opens a pool on host configured by options, whose connections
+// each resolve secrets again, and checks it.
+func connectMySQL(options *mysqlOptions, host, user string) (*sqlx.DB, error) {
+	// fail on an invalid profile rather than on the first connection
+	profileConnector, err := newProfileConnector(options.profile, host, user)
+	if err != nil {
+		return nil, err
+	}
+	var connector driver.Connector = profileConnector
+	if options.budget != nil {
+		connector = budgetedConnector{Connector: connector, budget: options.budget, addr: profileConnector.config.Addr}
+	}
+	db := sqlx.NewDb(sql.OpenDB(connector), "mysql")
+	db.SetMaxOpenConns(options.pool.MaxOpen)
+	if options.pool.MaxIdle != 0 {
+		db.SetMaxIdleConns(options.pool.MaxIdle)
+	}
+	db.SetConnMaxLifetime(options.pool.MaxLifetime)
+	db.SetConnMaxIdleTime(options.pool.MaxIdleTime)
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,162 @@
+package etl
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestConnectionProfile(t *testing.T) {
+	t.Run("TestConfigUsesProfile", func(t *testing.T) {
+		t.Setenv("ETL_TEST_PASSWORD", "s3cret")
+		profile := ConnectionProfile{
+			Password:    EnvSecret("ETL_TEST_PASSWORD"),
+			Port:        3307,
+			Ports:       map[string]int{"replica": 3308},
+			DialTimeout: 5 * time.Second,
+			ReadTimeout: time.Minute,
+			Params:      map[string]string{"parseTime": "true", "sql_mode": "'ANSI'"},
+		}
+
+		config, err := profile.config("primary", "etl")
+		assert.NoError(t, err)
+		assert.Equal(t, "primary:3307", config.Addr)
+		assert.Equal(t, "etl", config.User)
+		assert.Empty(t, config.Passwd, "connections resolve the password")
+		password, err := profile.password("primary")()
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret", password.Reveal())
+		assert.Equal(t, 5*time.Second, config.Timeout)
+		assert.Equal(t, time.Minute, config.ReadTimeout)
+		assert.True(t, config.ParseTime)
+		assert.Equal(t, "'ANSI'", config.Params["sql_mode"])
+
+		config, err = profile.config("replica", "etl")
+		assert.NoError(t, err)
+		assert.Equal(t, "replica:3308", config.Addr)
+
+		config, err = ConnectionProfile{}.config("dev:13306", "root")
+		assert.NoError(t, err)
+		assert.Equal(t, "dev:13306", config.Addr)
+	})
+
+	t.Run("TestHostCredentials", func(t *testing.T) {
+		t.Setenv("ETL_TEST_PASSWORD", "s3cret")
+		t.Setenv("ETL_TEST_REPLICA_PASSWORD", "replica-s3cret")
+		profile := ConnectionProfile{
+			User:      "etl",
+			Password:  EnvSecret("ETL_TEST_PASSWORD"),
+			Users:     map[string]string{"replica": "etl_ro"},
+			Passwords: map[string]SecretSource{"replica": EnvSecret("ETL_TEST_REPLICA_PASSWORD")},
+		}
+		for host, credentials := range map[string][2]string{"primary": {"etl", "s3cret"}, "replica": {"etl_ro", "replica-s3cret"}} {
+			connector, err := newProfileConnector(profile, host, "root")
+			assert.NoError(t, err)
+			assert.Equal(t, credentials[0], connector.config.User)
+			password, err := connector.password()
+			assert.NoError(t, err)
+			assert.Equal(t, credentials[1], password.Reveal())
+		}
+	})
+
+	t.Run("TestTLSFilesReadOnce", func(t *testing.T) {
+		ca := filepath.Join(t.TempDir(), "ca.pem")
+		assert.NoError(t, os.WriteFile(ca, selfSignedCA(t), 0600))
+		connector, err := newProfileConnector(ConnectionProfile{TLS: &TLSProfile{CAFile: ca}}, "127.0.0.1:1", "etl")
+		assert.NoError(t, err)
+		assert.NotNil(t, connector.config.TLS.RootCAs)
+
+		// connections keep the CA read when the connector was built
+		assert.NoError(t, os.Remove(ca))
+		_, err = connector.Connect(context.Background())
+		assert.Error(t, err, "nothing listens on port 1")
+		assert.NotErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("TestSecretResolvedPerConnection", func(t *testing.T) {
+		var resolved int
+		profile := ConnectionProfile{
+			Password: func() (Secret, error) {
+				resolved++
+				if resolved > 2 {
+					return "", fmt.Errorf("secret rotated away")
+				}
+				return Secret(fmt.Sprintf("s3cret-%d", resolved)), nil
+			},
+			DialTimeout: 100 * time.Millisecond,
+		}
+		connector, err := newProfileConnector(profile, "127.0.0.1:1", "etl")
+		assert.NoError(t, err)
+		for range 2 {
+			_, err := connector.Connect(context.Background())
+			assert.Error(t, err, "nothing listens on port 1")
+		}
+		assert.Equal(t, 2, resolved)
+		_, err = connector.Connect(context.Background())
+		assert.EqualError(t, err, "secret rotated away")
+	})
+
+	t.Run("TestFileSecret", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secrets")
+		assert.NoError(t, os.WriteFile(path, []byte("OTHER=x\nMYSQL_PASSWORD=\"s3cret\"\n"), 0600))
+
+		secret, err := FileSecret(path, "MYSQL_PASSWORD")()
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret", secret.Reveal())
+
+		_, err = FileSecret(path, "MISSING")()
+		assert.Error(t, err)
+	})
+
+	t.Run("TestSecretsAreRedacted", func(t *testing.T) {
+		secret := Secret("s3cret")
+		data, err := json.Marshal(struct{ Password Secret }{secret})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "s3cret")
+		assert.NotContains(t, fmt.Sprintf("%v %+v %#v %s", secret, secret, secret, secret), "s3cret")
+
+		core, logs := observer.New(zap.InfoLevel)
+		zap.New(core).Info("connecting",
+			zap.Object("profile", ConnectionProfile{User: "etl", Password: func() (Secret, error) { return secret, nil }}),
+			zap.Any("password", secret))
+		for _, entry := range logs.All() {
+			assert.NotContains(t, fmt.Sprint(entry.ContextMap()), "s3cret")
+		}
+	})
+}
+
+func selfSignedCA(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "etl test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
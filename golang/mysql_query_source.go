@@ -0,0 +1,122 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+const databasePlaceholder = "{database}"
+
+// MySQL server errors of a query on a database lacking what it reads.
+const (
+	erBadDb       = 1049
+	erNoSuchTable = 1146
+)
+
+// QueryError is a query failing in a database for another reason than the
+// database being empty or lacking its tables, such as a syntax error or an
+// unknown column. It fails the host, like a SchemaError.
+type QueryError struct {
+	Database string
+	Name     string
+	Err      error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query %s in %s: %v", e.Name, e.Database, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// NewMySQLQuerySource reads the rows of query, such as a join or a filtered
+// select, in every database matching dbMatching on each host. {database} in
+// query stands for the quoted name of each database. The query is wrapped in
+// a derived table paged on keyColumns, which are columns of its result mapped
+// to fields of T that identify a row; they should be indexed in the
+// underlying tables so MySQL can push the paging condition down. name stands
+// for the table in partition ids and in DBRecord.Table. Key-range splits and
+// watermarks only apply to tables and are ignored here.
+func NewMySQLQuerySource[T any](hosts []string, user, dbMatching, name, query string, keyColumns []string, opts ...MySQLOption) (ElementSource[DBRecord[T]], error) {
+	readers, err := queryReaders[T](name, query, keyColumns)
+	if err != nil {
+		return nil, err
+	}
+	return newMySQLSource(hosts, user, dbMatching, readers, opts)
+}
+
+func NewMySQLQueryShard[T any](shard, host, user, dbMatching, name, query string, keyColumns []string, opts ...MySQLOption) (ElementShard[DBRecord[T]], error) {
+	readers, err := queryReaders[T](name, query, keyColumns)
+	if err != nil {
+		return nil, err
+	}
+	return newMySQLShard(shard, host, user, dbMatching, readers, opts)
+}
+
+func queryReaders[T any](name, query string, keyColumns []string) (databaseReaders[T], error) {
+	if !strings.Contains(query, databasePlaceholder) {
+		return nil, fmt.Errorf("query %s does not use %s", name, databasePlaceholder)
+	}
+	projection, err := queryProjection[T](keyColumns)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, conn *sqlx.DB, database string, options *mysqlOptions) ([]ElementPartition[DBRecord[T], *sqlx.DB], []SkippedTable, error) {
+		from := fmt.Sprintf("(%s) AS %s", strings.ReplaceAll(query, databasePlaceholder, quoteIdentifier(database)), quoteIdentifier(name))
+		reader, err := newMySqlTableElementReader(conn, database, name, from, projection, scanStruct[T](projection))
+		if skipQuery(err) {
+			return nil, []SkippedTable{{Database: database, Table: name, Reason: err.Error()}}, nil
+		}
+		if err != nil {
+			return nil, nil, &QueryError{Database: database, Name: name, Err: err}
+		}
+		reader.applyReadOptions(options)
+		return []ElementPartition[DBRecord[T], *sqlx.DB]{reader}, nil, nil
+	}, nil
+}
+
+// queryProjection reads T like a table, keyed on keyColumns rather than on
+// the fields tagged sql:"pk".
+func queryProjection[T any](keyColumns []string) (*sqlProjection, error) {
+	if len(keyColumns) == 0 {
+		return nil, errors.New("a query needs at least one key column")
+	}
+	projection := extractPkColumn[T]()
+	projection.pkColumns, projection.pkIndexes, projection.pkTypes = nil, nil, nil
+	var record T
+	recordType := reflect.TypeOf(record)
+	for _, column := range keyColumns {
+		index := -1
+		for i := 0; i < recordType.NumField(); i++ {
+			if dbTag, ok := recordType.Field(i).Tag.Lookup("db"); ok && strings.Split(dbTag, ",")[0] == column {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("key column %s is not a db field of %T", column, record)
+		}
+		projection.pkColumns = append(projection.pkColumns, column)
+		projection.pkIndexes = append(projection.pkIndexes, index)
+		projection.pkTypes = append(projection.pkTypes, recordType.Field(index).Type)
+	}
+	return projection, nil
+}
+
+// skipQuery reports whether a database the query fails on with err is skipped
+// rather than failing the host: it has no row to read, or lacks the tables
+// the query reads.
+func skipQuery(err error) bool {
+	if errors.Is(err, ErrEmptyTable) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == erBadDb || mysqlErr.Number == erNoSuchTable)
+}
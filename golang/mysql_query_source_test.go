@@ -0,0 +1,63 @@
+package etl
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMySQLQuerySource(t *testing.T) {
+	t.Run("TestQueryIsPagedOnKeyColumns", func(t *testing.T) {
+		projection, err := queryProjection[customerRecord]([]string{"id"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"id"}, projection.pkColumns)
+		assert.Equal(t, []int{1}, projection.pkIndexes)
+
+		from := "(SELECT d.customer_id, d.id, d.data FROM `db`.deliveries d WHERE d.campaign_id = 5) AS `campaign`"
+		query, args := batchQuery(from, projection, 100, []interface{}{int64(42)}, keyRange{}, nil)
+		assert.Equal(t, "SELECT `customer_id`,`id`,`data` FROM "+from+" WHERE (`id`) > (?) ORDER BY `id` LIMIT 100 ", query)
+		assert.Equal(t, []interface{}{int64(42)}, args)
+	})
+
+	t.Run("TestRejectsInvalidQueries", func(t *testing.T) {
+		_, err := queryProjection[customerRecord]([]string{"missing"})
+		assert.Error(t, err)
+		_, err = queryReaders[customerRecord]("campaign", "SELECT * FROM deliveries", []string{"id"})
+		assert.Error(t, err, "the query must name the database to read")
+	})
+
+	t.Run("TestOnlySkipsDatabasesWithoutRows", func(t *testing.T) {
+		readers, err := queryReaders[fakeRecord]("campaign", "SELECT id FROM {database}.deliveries", []string{"id"})
+		assert.NoError(t, err)
+		read := func(queryErr error) ([]SkippedTable, error) {
+			server := &fakeServer{respond: func(string, []driver.NamedValue) fakeResult {
+				if queryErr != nil {
+					return fakeResult{err: queryErr}
+				}
+				return fakeResult{columns: []string{"id"}}
+			}}
+			db := server.open(1)
+			defer db.Close()
+			_, skipped, err := readers(context.Background(), db, "db", newMySQLOptions(nil))
+			return skipped, err
+		}
+
+		skipped, err := read(nil)
+		assert.NoError(t, err)
+		assert.Len(t, skipped, 1, "an empty result is skipped")
+		skipped, err = read(&mysql.MySQLError{Number: erNoSuchTable, Message: "Table 'db.deliveries' doesn't exist"})
+		assert.NoError(t, err)
+		assert.Len(t, skipped, 1, "a database without the table is skipped")
+
+		syntaxErr := &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}
+		skipped, err = read(syntaxErr)
+		var queryErr *QueryError
+		assert.True(t, errors.As(err, &queryErr))
+		assert.ErrorIs(t, err, syntaxErr)
+		assert.Empty(t, skipped)
+	})
+}
@@ -0,0 +1,134 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ReadMode int
+
+const (
+	// ReadPaged runs one keyset query per batch.
+	ReadPaged ReadMode = iota
+	// ReadStreaming runs a single query per partition and reads its batches
+	// from the open result set. The server keeps sending rows while the batch
+	// waits in the shard buffer, but the partition holds a connection until it
+	// is done, so the partitions of a chunk are streamed one after the other,
+	// and a consumer stalled for longer than net_write_timeout makes the
+	// server abort the query.
+	ReadStreaming
+	// ReadPrefetch runs one keyset query per batch like ReadPaged, starting the
+	// query of the next batch as soon as a batch is returned.
+	ReadPrefetch
+)
+
+// WithReadMode picks how partitions query their batches, ReadPaged by default.
+func WithReadMode(mode ReadMode) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.readMode = mode
+	}
+}
+
+type prefetchedPage[T any] struct {
+	records []*T
+	keys    [][]interface{}
+	err     error
+}
+
+// background returns a context outliving the calls to NextBatchContext, which
+// may carry a per-call deadline, for the queries left running between calls.
+// Close cancels it.
+func (r *MySqlTableElementReader[T]) background(ctx context.Context) context.Context {
+	if r.backgroundCtx == nil {
+		r.backgroundCtx, r.cancelBackground = context.WithCancel(context.WithoutCancel(ctx))
+	}
+	return r.backgroundCtx
+}
+
+func (r *MySqlTableElementReader[T]) readStream(ctx context.Context, conn sqlx.QueryerContext, batchSize int) ([]*T, [][]interface{}, error) {
+	if r.stream == nil {
+		if db, ok := conn.(*sqlx.DB); ok {
+			// only waiting for the connection observes ctx, the cursor outlives the call
+			streamConn, err := acquireConn(ctx, db, r.connectionWait)
+			if err != nil {
+				return nil, nil, fmt.Errorf("streaming %s: %w", r.Id(), err)
+			}
+			r.streamConn = streamConn
+			conn = streamConn
+		}
+		query, args := batchQuery(r.from, r.projection, 0, r.lastKey, r.keyRange, r.watermark)
+		rows, err := conn.QueryxContext(r.background(ctx), query, args...)
+		if err != nil {
+			return nil, nil, errors.Join(err, r.closeStream())
+		}
+		r.stream = rows
+	}
+	records, keys, exhausted, err := scanRows(ctx, r.stream, r.scan, batchSize)
+	if err == nil && exhausted {
+		err = r.closeStream()
+	}
+	return records, keys, err
+}
+
+// closeStream closes the stream and releases the connection it held.
+func (r *MySqlTableElementReader[T]) closeStream() error {
+	var err error
+	if r.stream != nil {
+		err = r.stream.Close()
+		r.stream = nil
+	}
+	if r.streamConn != nil {
+		err = errors.Join(err, r.streamConn.Close())
+		r.streamConn = nil
+	}
+	return err
+}
+
+func (r *MySqlTableElementReader[T]) readPrefetched(ctx context.Context, conn sqlx.QueryerContext, batchSize int) ([]*T, [][]interface{}, error) {
+	if r.prefetch == nil {
+		r.prefetchPage(ctx, conn, batchSize, r.lastKey)
+	}
+	var page prefetchedPage[T]
+	select {
+	case page = <-r.prefetch:
+	case <-ctx.Done():
+		// the page stays pending for the next call
+		return nil, nil, ctx.Err()
+	}
+	r.prefetch = nil
+	if page.err != nil {
+		return nil, nil, page.err
+	}
+	if len(page.records) == batchSize {
+		r.prefetchPage(ctx, conn, batchSize, page.keys[len(page.keys)-1])
+	}
+	return page.records, page.keys, nil
+}
+
+func (r *MySqlTableElementReader[T]) prefetchPage(ctx context.Context, conn sqlx.QueryerContext, batchSize int, lastKey []interface{}) {
+	prefetch := make(chan prefetchedPage[T], 1)
+	r.prefetch = prefetch
+	background := r.background(ctx)
+	go func() {
+		records, keys, err := readMySQlTableInBatch(background, conn, r.from, r.projection, r.scan, batchSize, lastKey, r.keyRange, r.watermark)
+		prefetch <- prefetchedPage[T]{records, keys, err}
+	}()
+}
+
+func (r *MySqlTableElementReader[T]) closeReads() error {
+	// cancelling first keeps Close from draining the rest of a stream
+	if r.cancelBackground != nil {
+		r.cancelBackground()
+		r.backgroundCtx, r.cancelBackground = nil, nil
+	}
+	err := r.closeStream()
+	if r.prefetch != nil {
+		// the query sharing the snapshot connection must end before it is released
+		<-r.prefetch
+		r.prefetch = nil
+	}
+	return err
+}
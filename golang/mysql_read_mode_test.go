@@ -0,0 +1,56 @@
+package etl
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestStreaming(t *testing.T) {
+	server := &fakeServer{respond: func(query string, args []driver.NamedValue) fakeResult {
+		return fakeTableRows(6, query, args)
+	}}
+	options := newMySQLOptions([]MySQLOption{WithReadMode(ReadStreaming), WithPool(MySQLPool{MaxOpen: 1, WaitTimeout: 50 * time.Millisecond})})
+
+	t.Run("TestChunkStreamsPartitionsInTurn", func(t *testing.T) {
+		db := server.open(1)
+		// both partitions share a chunk and the only connection of the pool
+		shard := &MySQLShard[fakeRecord]{
+			shard: "s",
+			pool:  NewSharedResource(func() (*sqlx.DB, error) { return server.open(1), nil }),
+			partitions: []ElementPartition[DBRecord[fakeRecord], *sqlx.DB]{
+				newFakeReader(t, db, options),
+				newFakeReader(t, db, options),
+			},
+		}
+		var report FailureReport
+		worker := NewShardWorker[DBRecord[fakeRecord]]("s", 10, zap.NewNop()).WithFailureReport(&report)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, worker.Consume(ctx, shard, 1, 2, func(WorkerMetrics) {}, 0))
+		assert.NoError(t, ctx.Err(), "reads blocked on the pool")
+		assert.Empty(t, report.Failures())
+		read := 0
+		for batch := range worker.buffer {
+			read += len(batch.Records)
+		}
+		assert.Equal(t, 12, read)
+	})
+
+	t.Run("TestStarvedStreamFails", func(t *testing.T) {
+		db := server.open(1)
+		defer db.Close()
+		reader := newFakeReader(t, db, options)
+		held, err := db.Connx(context.Background())
+		assert.NoError(t, err)
+		defer held.Close()
+
+		_, _, err = reader.NextBatchContext(context.Background(), db, 2)
+		assert.ErrorIs(t, err, ErrConnectionStarved)
+	})
+}
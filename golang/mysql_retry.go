@@ -0,0 +1,97 @@
+package etl
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// MySQL server errors worth retrying a read for.
+const (
+	erConCount                 = 1040
+	erServerShutdown           = 1053
+	erTooManyUserConnections   = 1203
+	erLockWaitTimeout          = 1205
+	erLockDeadlock             = 1213
+	erOptionPreventsStatement  = 1290
+	erReadOnlyMode             = 1836
+	erConnectionKilled         = 1927
+	erClientInteractionTimeout = 4031
+)
+
+// database/sql keeps 2 idle connections unless told otherwise.
+const defaultMaxIdleConns = 2
+
+// WithReadRetry retries the batches failing with a transient error according
+// to policy, reading the same page again from the last key returned, except
+// for partitions that lost their snapshot connection. Unless
+// policy.Retryable is set, errors are classified by IsTransientMySQLError.
+// Reads are retried with DefaultRetryPolicy by default; a MaxAttempts of 1
+// disables retries.
+func WithReadRetry(policy RetryPolicy) MySQLOption {
+	return func(o *mysqlOptions) {
+		if policy.Retryable == nil {
+			policy.Retryable = IsTransientMySQLError
+		}
+		o.retry = policy
+	}
+}
+
+func defaultMySQLRetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.Retryable = IsTransientMySQLError
+	return policy
+}
+
+// IsTransientMySQLError reports whether a read failing with err is worth
+// retrying: lost connections, deadlocks, lock wait timeouts, too many
+// connections and a server shut down or turned read-only by a failover, as
+// well as the errors of IsTransientError.
+func IsTransientMySQLError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case erConCount, erTooManyUserConnections, erLockWaitTimeout, erLockDeadlock:
+			return true
+		}
+	}
+	return isLostConnection(err) || IsTransientError(err)
+}
+
+// isLostConnection reports errors after which the connections of the pool
+// may point at a server that went away or was demoted.
+func isLostConnection(err error) bool {
+	if errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case erServerShutdown, erOptionPreventsStatement, erReadOnlyMode, erConnectionKilled, erClientInteractionTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// reconnect drops the stream or prefetch of a failed read. After a lost
+// connection it also drops the idle connections of the pool, so the retry
+// dials the host again.
+func (r *MySqlTableElementReader[T]) reconnect(db *sqlx.DB, err error) {
+	_ = r.closeReads()
+	if !isLostConnection(err) {
+		return
+	}
+	maxIdle := r.maxIdleConns
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdleConns
+	}
+	db.SetMaxIdleConns(-1)
+	db.SetMaxIdleConns(maxIdle)
+}
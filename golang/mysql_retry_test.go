@@ -0,0 +1,90 @@
+package etl
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientMySQLError(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		transient bool
+		lost      bool
+	}{
+		{"InvalidConnection", fmt.Errorf("reading: %w", mysql.ErrInvalidConn), true, true},
+		{"LockWaitTimeout", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, true, false},
+		{"Deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, true, false},
+		{"TooManyConnections", &mysql.MySQLError{Number: 1040, Message: "Too many connections"}, true, false},
+		{"ReadOnlyFailover", &mysql.MySQLError{Number: 1290, Message: "running with the --read-only option"}, true, true},
+		{"SyntaxError", &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, false, false},
+		{"UnknownColumn", errors.New("sql: converting argument"), false, false},
+		{"Nil", nil, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.transient, IsTransientMySQLError(tc.err))
+			assert.Equal(t, tc.lost, tc.err != nil && isLostConnection(tc.err))
+		})
+	}
+
+	t.Run("TestReadRetryDefaultsToMySQLErrors", func(t *testing.T) {
+		options := newMySQLOptions([]MySQLOption{WithReadRetry(RetryPolicy{MaxAttempts: 2})})
+		assert.True(t, options.retry.retryable(&mysql.MySQLError{Number: 1213}))
+		assert.Equal(t, 4, newMySQLOptions(nil).retry.MaxAttempts, "reads are retried by default")
+	})
+}
+
+func TestReadRetry(t *testing.T) {
+	newServer := func(failing *bool) *fakeServer {
+		return &fakeServer{respond: func(query string, args []driver.NamedValue) fakeResult {
+			if !strings.HasPrefix(query, "SELECT") {
+				return fakeResult{}
+			}
+			if *failing {
+				*failing = false
+				return fakeResult{err: mysql.ErrInvalidConn}
+			}
+			return fakeTableRows(5, query, args)
+		}}
+	}
+	retry := WithReadRetry(RetryPolicy{MaxAttempts: 3})
+
+	t.Run("TestRetriesPageAfterLostConnection", func(t *testing.T) {
+		failing := false
+		db := newServer(&failing).open(2)
+		reader := newFakeReader(t, db, newMySQLOptions([]MySQLOption{retry}))
+
+		records, _, err := reader.NextBatchContext(context.Background(), db, 2)
+		assert.NoError(t, err)
+		assert.Len(t, records, 2)
+		failing = true
+		records, offset, err := reader.NextBatchContext(context.Background(), db, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(4)}, offset, "the failed page is read again from the last key")
+		assert.Equal(t, int64(3), records[0].Record.Id)
+	})
+
+	t.Run("TestSnapshotLostFailsPartition", func(t *testing.T) {
+		failing := false
+		server := newServer(&failing)
+		db := server.open(2)
+		reader := newFakeReader(t, db, newMySQLOptions([]MySQLOption{retry, WithConsistentSnapshot(0)}))
+
+		_, _, err := reader.NextBatchContext(context.Background(), db, 2)
+		assert.NoError(t, err)
+		failing = true
+		selects := len(server.statements)
+		_, _, err = reader.NextBatchContext(context.Background(), db, 2)
+		assert.ErrorIs(t, err, ErrSnapshotLost)
+		assert.ErrorIs(t, err, mysql.ErrInvalidConn)
+		assert.Equal(t, selects+2, len(server.statements), "the page is not retried on a new snapshot, only the snapshot is committed")
+		assert.Equal(t, "COMMIT", server.statements[len(server.statements)-1])
+	})
+}
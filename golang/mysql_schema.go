@@ -0,0 +1,204 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SchemaError lists every way a record type does not match the table it
+// reads, found before reading any row.
+type SchemaError struct {
+	Database string
+	Table    string
+	Record   string
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s does not match %s.%s:\n  - %s", e.Record, e.Database, e.Table, strings.Join(e.Problems, "\n  - "))
+}
+
+// validateTableSchema checks that every db field of T is a column of the
+// table that can be scanned into it, NULL values included, and that the
+// fields tagged sql:"pk" are the primary key or a unique index, which keyset
+// paging relies on.
+func validateTableSchema[T any](ctx context.Context, conn *sqlx.DB, database, table string) error {
+	columns, err := describeTable(ctx, conn, database, table)
+	if err != nil {
+		return err
+	}
+	uniqueKeys, err := uniqueIndexes(ctx, conn, database, table)
+	if err != nil {
+		return err
+	}
+	parseTime, err := parsesTime(ctx, conn)
+	if err != nil {
+		return err
+	}
+	var record T
+	problems := schemaProblems(reflect.TypeOf(record), columns, uniqueKeys, parseTime)
+	if len(problems) > 0 {
+		return &SchemaError{Database: database, Table: table, Record: fmt.Sprintf("%T", record), Problems: problems}
+	}
+	return nil
+}
+
+// uniqueIndexes returns the columns of the primary key and unique indexes of
+// a table, in index order.
+func uniqueIndexes(ctx context.Context, conn *sqlx.DB, database, table string) (map[string][]string, error) {
+	var entries []struct {
+		Index  string `db:"index_name"`
+		Column string `db:"column_name"`
+	}
+	err := conn.SelectContext(ctx, &entries, `
+		SELECT INDEX_NAME AS index_name, COLUMN_NAME AS column_name
+		FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND NON_UNIQUE = 0
+		ORDER BY INDEX_NAME, SEQ_IN_INDEX`, database, table)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make(map[string][]string)
+	for _, entry := range entries {
+		indexes[entry.Index] = append(indexes[entry.Index], entry.Column)
+	}
+	return indexes, nil
+}
+
+// parsesTime reports whether conn returns DATETIME values as time.Time, as
+// the parseTime DSN parameter asks, rather than as bytes.
+func parsesTime(ctx context.Context, conn *sqlx.DB) (bool, error) {
+	var value interface{}
+	if err := conn.QueryRowxContext(ctx, "SELECT CAST('2000-01-01' AS DATETIME)").Scan(&value); err != nil {
+		return false, err
+	}
+	_, ok := value.(time.Time)
+	return ok, nil
+}
+
+func schemaProblems(recordType reflect.Type, columns []columnInfo, uniqueKeys map[string][]string, parseTime bool) []string {
+	byName := make(map[string]columnInfo, len(columns))
+	for _, column := range columns {
+		byName[strings.ToLower(column.Name)] = column
+	}
+	var (
+		problems []string
+		fields   int
+		pk       []string
+	)
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		name, tagged := field.Tag.Lookup("db")
+		name = strings.Split(name, ",")[0]
+		isPk := strings.Contains(field.Tag.Get("sql"), "pk")
+		if !tagged || name == "" || name == "-" {
+			if isPk {
+				problems = append(problems, fmt.Sprintf("field %s is tagged sql:\"pk\" but has no db tag", field.Name))
+			}
+			continue
+		}
+		fields++
+		if isPk {
+			pk = append(pk, name)
+		}
+		column, ok := byName[strings.ToLower(name)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("field %s reads column %s, which does not exist", field.Name, name))
+			continue
+		}
+		switch {
+		case !scannable(field.Type, column, parseTime):
+			problems = append(problems, fmt.Sprintf("field %s of type %s cannot hold column %s of type %s", field.Name, field.Type, name, column.ColumnType))
+		case column.Nullable && !nullable(field.Type):
+			problems = append(problems, fmt.Sprintf("field %s of type %s cannot hold the NULL values of column %s, a pointer or sql.Null type can", field.Name, field.Type, name))
+		}
+	}
+	if fields == 0 {
+		return append(problems, "no field is tagged db")
+	}
+	if len(pk) == 0 {
+		return append(problems, "no field is tagged sql:\"pk\"")
+	}
+	if !isUniqueKey(pk, uniqueKeys) {
+		problems = append(problems, fmt.Sprintf("key (%s) is neither the primary key nor a unique index", strings.Join(pk, ", ")))
+	}
+	return problems
+}
+
+func isUniqueKey(pk []string, uniqueKeys map[string][]string) bool {
+	for _, index := range uniqueKeys {
+		if len(index) != len(pk) {
+			continue
+		}
+		matches := true
+		for i := range index {
+			matches = matches && strings.EqualFold(index[i], pk[i])
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+)
+
+// scannable reports whether database/sql can scan column into a field of
+// type fieldType. Types implementing sql.Scanner are trusted to. Dates are
+// only scanned into time.Time when the connection parses them.
+func scannable(fieldType reflect.Type, column columnInfo, parseTime bool) bool {
+	for fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	if reflect.PointerTo(fieldType).Implements(scannerType) {
+		return true
+	}
+	if fieldType == timeType {
+		switch column.DataType {
+		case "date", "datetime", "timestamp":
+			return parseTime
+		}
+		return false
+	}
+	if fieldType.ConvertibleTo(bytesType) {
+		return true
+	}
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Interface:
+		return true
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return column.isInteger()
+	case reflect.Float32, reflect.Float64:
+		switch column.DataType {
+		case "float", "double", "decimal":
+			return true
+		}
+		return column.isInteger()
+	}
+	return false
+}
+
+// nullable reports whether database/sql can scan NULL into a field of type
+// fieldType: pointers, sql.Null types and other scanners, byte slices and
+// interfaces can.
+func nullable(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return true
+	case reflect.Slice:
+		return fieldType.ConvertibleTo(bytesType)
+	}
+	return reflect.PointerTo(fieldType).Implements(scannerType)
+}
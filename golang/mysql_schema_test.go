@@ -0,0 +1,116 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaRecord struct {
+	CustomerId int64          `db:"customer_id" sql:"pk"`
+	Id         uint64         `db:"id" sql:"pk"`
+	Name       sql.NullString `db:"name"`
+	CreatedAt  time.Time      `db:"created_at"`
+	Payload    []byte         `db:"payload"`
+	Ignored    string         `db:"-"`
+}
+
+func TestSchemaValidation(t *testing.T) {
+	columns := []columnInfo{
+		{Name: "customer_id", DataType: "bigint", ColumnType: "bigint"},
+		{Name: "id", DataType: "bigint", ColumnType: "bigint unsigned"},
+		{Name: "name", DataType: "varchar", ColumnType: "varchar(64)", Nullable: true},
+		{Name: "created_at", DataType: "datetime", ColumnType: "datetime"},
+		{Name: "payload", DataType: "json", ColumnType: "json", Nullable: true},
+	}
+	primary := map[string][]string{"PRIMARY": {"customer_id", "id"}}
+
+	t.Run("TestMatchingRecord", func(t *testing.T) {
+		assert.Empty(t, schemaProblems(reflect.TypeOf(schemaRecord{}), columns, primary, true))
+	})
+
+	t.Run("TestReportsEveryProblem", func(t *testing.T) {
+		type record struct {
+			Id        string    `db:"id" sql:"pk"`
+			Nmae      string    `db:"nmae"`
+			CreatedAt int64     `db:"created_at"`
+			Key       int64     `sql:"pk"`
+			Payload   time.Time `db:"payload"`
+		}
+		problems := schemaProblems(reflect.TypeOf(record{}), columns, map[string][]string{"PRIMARY": {"customer_id", "id"}, "uniq_name": {"name"}}, true)
+		assert.Equal(t, []string{
+			"field Nmae reads column nmae, which does not exist",
+			"field CreatedAt of type int64 cannot hold column created_at of type datetime",
+			"field Key is tagged sql:\"pk\" but has no db tag",
+			"field Payload of type time.Time cannot hold column payload of type json",
+			"key (id) is neither the primary key nor a unique index",
+		}, problems)
+
+		err := &SchemaError{Database: "production_env1", Table: "customers", Record: "etl.record", Problems: problems[:2]}
+		assert.Equal(t, "etl.record does not match production_env1.customers:\n"+
+			"  - field Nmae reads column nmae, which does not exist\n"+
+			"  - field CreatedAt of type int64 cannot hold column created_at of type datetime", err.Error())
+	})
+
+	t.Run("TestNullableColumnsNeedNullableFields", func(t *testing.T) {
+		type record struct {
+			CustomerId int64           `db:"customer_id" sql:"pk"`
+			Id         uint64          `db:"id" sql:"pk"`
+			Name       string          `db:"name"`
+			Payload    json.RawMessage `db:"payload"`
+		}
+		assert.Equal(t, []string{
+			"field Name of type string cannot hold the NULL values of column name, a pointer or sql.Null type can",
+		}, schemaProblems(reflect.TypeOf(record{}), columns, primary, true))
+
+		type pointers struct {
+			CustomerId int64   `db:"customer_id" sql:"pk"`
+			Id         uint64  `db:"id" sql:"pk"`
+			Name       *string `db:"name"`
+			Payload    any     `db:"payload"`
+		}
+		assert.Empty(t, schemaProblems(reflect.TypeOf(pointers{}), columns, primary, true))
+	})
+
+	t.Run("TestDatesNeedParseTime", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"field CreatedAt of type time.Time cannot hold column created_at of type datetime",
+		}, schemaProblems(reflect.TypeOf(schemaRecord{}), columns, primary, false))
+
+		server := &fakeServer{respond: func(query string, args []driver.NamedValue) fakeResult {
+			return fakeResult{columns: []string{"value"}, rows: [][]driver.Value{{[]byte("2000-01-01 00:00:00")}}}
+		}}
+		db := server.open(1)
+		defer db.Close()
+		parseTime, err := parsesTime(context.Background(), db)
+		assert.NoError(t, err)
+		assert.False(t, parseTime)
+		server.respond = func(query string, args []driver.NamedValue) fakeResult {
+			return fakeResult{columns: []string{"value"}, rows: [][]driver.Value{{time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}}}
+		}
+		parseTime, err = parsesTime(context.Background(), db)
+		assert.NoError(t, err)
+		assert.True(t, parseTime)
+	})
+
+	t.Run("TestMissingTags", func(t *testing.T) {
+		type untagged struct{ Id int64 }
+		assert.Equal(t, []string{"no field is tagged db"}, schemaProblems(reflect.TypeOf(untagged{}), columns, primary, true))
+		type noPk struct {
+			Id int64 `db:"id"`
+		}
+		assert.Equal(t, []string{"no field is tagged sql:\"pk\""}, schemaProblems(reflect.TypeOf(noPk{}), columns, primary, true))
+	})
+
+	t.Run("TestQuoteIdentifier", func(t *testing.T) {
+		assert.Equal(t, "`delivs_2024_11`", quoteIdentifier("delivs_2024_11"))
+		assert.Equal(t, "`a``b`", quoteIdentifier("a`b"))
+		assert.Equal(t, "`db`.`ta``ble`", tableFrom("db", "ta`ble"))
+	})
+}
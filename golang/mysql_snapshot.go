@@ -0,0 +1,73 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrSnapshotTooOld = errors.New("snapshot too old")
+	// ErrSnapshotLost fails a partition whose snapshot connection was lost,
+	// as reading on from a new snapshot would mix two points in time.
+	ErrSnapshotLost = errors.New("snapshot connection lost")
+)
+
+// WithConsistentSnapshot makes every partition read all its batches from one
+// InnoDB snapshot, taken on a dedicated connection when its first batch is
+// read, so rows changed during the run are not picked up. Unless maxAge is 0,
+// a partition still reading after maxAge fails with ErrSnapshotTooOld rather
+// than hold back purge on the server any longer, and a partition losing its
+// connection fails with ErrSnapshotLost rather than being retried. Key-range
+// partitions of a table each take their own snapshot, and a resumed
+// partition takes a new one. The partitions of a chunk are read one after
+// the other, so a chunk holds a single snapshot at a time and maxAge bounds
+// the read of one partition.
+func WithConsistentSnapshot(maxAge time.Duration) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.snapshots = true
+		o.snapshotMaxAge = maxAge
+	}
+}
+
+type tableSnapshot struct {
+	conn    *sqlx.Conn
+	started time.Time
+	maxAge  time.Duration
+}
+
+func openSnapshot(ctx context.Context, db *sqlx.DB, maxAge, wait time.Duration) (*tableSnapshot, error) {
+	conn, err := acquireConn(ctx, db, wait)
+	if err != nil {
+		return nil, err
+	}
+	for _, statement := range []string{
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY",
+	} {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return &tableSnapshot{conn: conn, started: time.Now(), maxAge: maxAge}, nil
+}
+
+func (s *tableSnapshot) check() error {
+	if age := time.Since(s.started); s.maxAge > 0 && age > s.maxAge {
+		return fmt.Errorf("%w: taken %s ago, allowed %s", ErrSnapshotTooOld, age.Round(time.Second), s.maxAge)
+	}
+	return nil
+}
+
+func (s *tableSnapshot) Close() error {
+	_, commitErr := s.conn.ExecContext(context.Background(), "COMMIT")
+	closeErr := s.conn.Close()
+	if commitErr != nil {
+		return commitErr
+	}
+	return closeErr
+}
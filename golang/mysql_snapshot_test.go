@@ -0,0 +1,64 @@
+package etl
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Run("TestSnapshotFailsPastMaxAge", func(t *testing.T) {
+		snapshot := &tableSnapshot{started: time.Now().Add(-2 * time.Hour), maxAge: time.Hour}
+		assert.ErrorIs(t, snapshot.check(), ErrSnapshotTooOld)
+
+		snapshot.maxAge = 0
+		assert.NoError(t, snapshot.check(), "a zero max age never expires")
+	})
+
+	server := &fakeServer{respond: func(query string, args []driver.NamedValue) fakeResult {
+		return fakeTableRows(6, query, args)
+	}}
+	options := newMySQLOptions([]MySQLOption{WithConsistentSnapshot(time.Hour), WithPool(MySQLPool{MaxOpen: 1, WaitTimeout: 50 * time.Millisecond})})
+	snapshotShard := func(db *sqlx.DB, pool *sqlx.DB) *MySQLShard[fakeRecord] {
+		return &MySQLShard[fakeRecord]{
+			shard: "s",
+			pool:  NewSharedResource(func() (*sqlx.DB, error) { return pool, nil }),
+			partitions: []ElementPartition[DBRecord[fakeRecord], *sqlx.DB]{
+				newFakeReader(t, db, options),
+				newFakeReader(t, db, options),
+			},
+		}
+	}
+
+	t.Run("TestChunkHoldsOneSnapshotAtATime", func(t *testing.T) {
+		shard := snapshotShard(server.open(1), server.open(1))
+		worker := NewShardWorker[DBRecord[fakeRecord]]("s", 10, zap.NewNop())
+		assert.NoError(t, worker.Consume(context.Background(), shard, 1, 2, func(WorkerMetrics) {}, 0))
+		read := 0
+		for batch := range worker.buffer {
+			read += len(batch.Records)
+		}
+		assert.Equal(t, 12, read)
+	})
+
+	t.Run("TestStarvedSnapshotFailsTheShard", func(t *testing.T) {
+		pool := server.open(1)
+		shard := snapshotShard(server.open(1), pool)
+		held, err := pool.Connx(context.Background())
+		assert.NoError(t, err)
+		defer held.Close()
+
+		worker := NewShardWorker[DBRecord[fakeRecord]]("s", 10, zap.NewNop())
+		go func() {
+			for range worker.buffer {
+			}
+		}()
+		err = worker.Consume(context.Background(), shard, 1, 2, func(WorkerMetrics) {}, 0)
+		assert.ErrorIs(t, err, ErrConnectionStarved)
+	})
+}
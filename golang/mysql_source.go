@@ -1,13 +1,18 @@
 package etl
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/errgroup"
 )
 
 type DBRecord[T any] struct {
@@ -15,20 +20,147 @@ type DBRecord[T any] struct {
 	Table    string
 	Id       any
 	Record   *T
+	// Labels holds the named groups captured by dbMatching from DataBase.
+	Labels Labels `json:",omitempty"`
+}
+
+func (r *DBRecord[T]) RecordLabels() Labels {
+	return r.Labels
+}
+
+func (r *DBRecord[T]) RecordKey() any {
+	return r.Id
+}
+
+type mysqlOptions struct {
+	split           KeyRangeSplit
+	columns         []string
+	watermarkColumn string
+	watermarks      WatermarkStore
+	tableRange      *tableDateRange
+	snapshots       bool
+	snapshotMaxAge  time.Duration
+	readMode        ReadMode
+	profile         ConnectionProfile
+	pool            MySQLPool
+	budget          *ConnectionBudget
+	retry           RetryPolicy
+	throttle        *Throttle
+
+	// shard is the shard the readers are built for, set by preflightShard
+	shard string
+
+	preflight            *PreflightReport
+	partialShards        bool
+	preflightParallelism int
+}
+
+type MySQLOption func(*mysqlOptions)
+
+func newMySQLOptions(opts []MySQLOption) *mysqlOptions {
+	options := &mysqlOptions{preflightParallelism: 16, retry: defaultMySQLRetryPolicy()}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithKeyRangeSplit splits every table into key-range partitions that can be
+// read in parallel by the shard's readers. Their checkpoints record the
+// bounds of each range, and resuming fails with ErrKeyRangeChanged once a
+// table is split differently, which split.Checkpoints prevents by keeping
+// the boundaries of the first run.
+func WithKeyRangeSplit(split KeyRangeSplit) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.split = split
+	}
+}
+
+// databaseReaders builds the partitions reading one database, along with the
+// tables it had to skip.
+type databaseReaders[T any] func(ctx context.Context, conn *sqlx.DB, database string, options *mysqlOptions) ([]ElementPartition[DBRecord[T], *sqlx.DB], []SkippedTable, error)
+
+// tableReaders builds the partitions reading one table of a database.
+type tableReaders[T any] func(ctx context.Context, conn *sqlx.DB, database, table string, options *mysqlOptions) ([]ElementPartition[DBRecord[T], *sqlx.DB], error)
+
+// matchingTables reads every table of a database matching table.
+func matchingTables[T any](table string, readers tableReaders[T]) databaseReaders[T] {
+	return func(ctx context.Context, conn *sqlx.DB, database string, options *mysqlOptions) ([]ElementPartition[DBRecord[T], *sqlx.DB], []SkippedTable, error) {
+		matchesTable, err := tableMatcher(table, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		tables, err := scanTables(conn, database, matchesTable)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(tables) == 0 {
+			return nil, []SkippedTable{{Database: database, Reason: fmt.Sprintf("no table matches %s", table)}}, nil
+		}
+		var (
+			partitions []ElementPartition[DBRecord[T], *sqlx.DB]
+			skipped    []SkippedTable
+		)
+		for _, table := range tables {
+			tableReaders, err := readers(ctx, conn, database, table, options)
+			var schemaErr *SchemaError
+			if errors.As(err, &schemaErr) {
+				return nil, skipped, err
+			}
+			if err != nil {
+				skipped = append(skipped, SkippedTable{Database: database, Table: table, Reason: err.Error()})
+				continue
+			}
+			partitions = append(partitions, tableReaders...)
+		}
+		return partitions, skipped, nil
+	}
 }
 
 type MySQLSource[T any] struct {
 	shards []ElementShard[DBRecord[T]]
 }
 
-func NewMySQLSource[T any](hosts []string, user, dbMatching, table string) (ElementSource[DBRecord[T]], error) {
-	var shards []ElementShard[DBRecord[T]]
-	for _, host := range hosts {
-		shard, err := NewMySQLShard[T](host, host, user, dbMatching, table)
-		if err != nil {
-			return nil, err
+// NewMySQLSource reads every table matching table in every database matching
+// dbMatching on each host. table is a regular expression matching whole table
+// names, or a date template when WithTableDateRange is set.
+func NewMySQLSource[T any](hosts []string, user, dbMatching, table string, opts ...MySQLOption) (ElementSource[DBRecord[T]], error) {
+	return newMySQLSource(hosts, user, dbMatching, matchingTables(table, structTableReaders[T]), opts)
+}
+
+// newMySQLSource checks the hosts in parallel. A host that cannot be read
+// fails the source unless WithPartialShards is set, in which case it is left
+// out as long as one host remains.
+func newMySQLSource[T any](hosts []string, user, dbMatching string, readers databaseReaders[T], opts []MySQLOption) (ElementSource[DBRecord[T]], error) {
+	options := newMySQLOptions(opts)
+	reports := make([]HostReport, len(hosts))
+	hostShards := make([]*MySQLShard[T], len(hosts))
+	var checks errgroup.Group
+	checks.SetLimit(options.preflightParallelism)
+	for i, host := range hosts {
+		checks.Go(func() error {
+			hostShards[i], reports[i] = preflightShard(host, host, user, dbMatching, readers, options)
+			return nil
+		})
+	}
+	_ = checks.Wait()
+	if options.preflight != nil {
+		options.preflight.Hosts = reports
+	}
+
+	var (
+		shards []ElementShard[DBRecord[T]]
+		errs   []error
+	)
+	for i, report := range reports {
+		if report.Err != nil {
+			errs = append(errs, fmt.Errorf("host %s: %w", report.Host, report.Err))
+			continue
 		}
-		shards = append(shards, shard)
+		shards = append(shards, hostShards[i])
+	}
+	if len(errs) > 0 && (!options.partialShards || len(shards) == 0) {
+		return nil, errors.Join(errs...)
 	}
 
 	return &MySQLSource[T]{
@@ -47,131 +179,399 @@ func (s *MySQLSource[T]) Shards() ([]ElementShard[DBRecord[T]], error) {
 type MySQLShard[T any] struct {
 	shard string
 
-	connUrl    string
-	partitions []ElementPartition[DBRecord[T]]
+	pool       *SharedResource[*sqlx.DB]
+	throttle   *hostThrottle
+	partitions []ElementPartition[DBRecord[T], *sqlx.DB]
+}
+
+func NewMySQLShard[T any](shard, host, user, dbMatching, table string, opts ...MySQLOption) (ElementShard[DBRecord[T]], error) {
+	return newMySQLShard(shard, host, user, dbMatching, matchingTables(table, structTableReaders[T]), opts)
+}
+
+func newMySQLShard[T any](shard, host, user, dbMatching string, readers databaseReaders[T], opts []MySQLOption) (ElementShard[DBRecord[T]], error) {
+	options := newMySQLOptions(opts)
+	mysqlShard, report := preflightShard(shard, host, user, dbMatching, readers, options)
+	if options.preflight != nil {
+		options.preflight.Hosts = []HostReport{report}
+	}
+	if report.Err != nil {
+		return nil, report.Err
+	}
+	return mysqlShard, nil
 }
 
-func NewMySQLShard[T any](shard, host, user, dbMatching, table string) (ElementShard[DBRecord[T]], error) {
+// preflightShard connects to host and opens the partitions of every matching
+// database, reporting what will be read and what was skipped.
+func preflightShard[T any](shard, host, user, dbMatching string, readers databaseReaders[T], options *mysqlOptions) (*MySQLShard[T], HostReport) {
+	started := time.Now()
+	report := HostReport{Host: host}
+	defer func() {
+		report.Duration = time.Since(started)
+	}()
 
-	conn, err := sqlx.Connect("mysql", fmt.Sprintf("%s@tcp(%s:3306)/", user, host))
+	conn, err := connectMySQL(options, host, user)
 	if err != nil {
-		return nil, err
+		report.Err = err
+		return nil, report
 	}
 	defer conn.Close()
 
 	databases, err := scanDatabases(conn, dbMatching)
 	if err != nil {
-		return nil, err
+		report.Err = err
+		return nil, report
 	}
 
-	var partitions []ElementPartition[DBRecord[T]]
+	shardOptions := *options
+	shardOptions.shard = shard
+	var partitions []ElementPartition[DBRecord[T], *sqlx.DB]
 	for _, database := range databases {
-		partition, err := NewMySqlTableElementReader[T](conn, database, table)
+		databaseReaders, skipped, err := readers(context.Background(), conn, database.name, &shardOptions)
+		var (
+			schemaErr *SchemaError
+			queryErr  *QueryError
+		)
+		if errors.As(err, &schemaErr) || errors.As(err, &queryErr) {
+			// a record type that does not match its tables, or a query that
+			// cannot run, fails the host
+			report.Err = err
+			return nil, report
+		}
 		if err != nil {
-			continue
-			//return nil, err
+			skipped = append(skipped, SkippedTable{Database: database.name, Reason: err.Error()})
 		}
-		partitions = append(partitions, partition)
+		report.Skipped = append(report.Skipped, skipped...)
+		for _, partition := range databaseReaders {
+			if reader, ok := partition.(*MySqlTableElementReader[T]); ok {
+				reader.labels = database.labels
+			}
+			report.Partitions = append(report.Partitions, partition.Id())
+		}
+		partitions = append(partitions, databaseReaders...)
 	}
-	connUrl := fmt.Sprintf("%s@tcp(%s:3306)/", user, host)
-	return &MySQLShard[T]{
-		shard:      shard,
-		connUrl:    connUrl,
+	mysqlShard := &MySQLShard[T]{
+		shard: shard,
+		pool: NewSharedResource(func() (*sqlx.DB, error) {
+			return connectMySQL(options, host, user)
+		}),
 		partitions: partitions,
-	}, nil
+	}
+	if options.throttle != nil {
+		samplerOptions := *options
+		samplerOptions.pool = MySQLPool{MaxOpen: 1, MaxIdle: 1}
+		mysqlShard.throttle = newHostThrottle(*options.throttle, NewSharedResource(func() (*sqlx.DB, error) {
+			return connectMySQL(&samplerOptions, host, user)
+		}))
+	}
+	return mysqlShard, report
+}
+
+func structTableReaders[T any](ctx context.Context, conn *sqlx.DB, database, table string, options *mysqlOptions) ([]ElementPartition[DBRecord[T], *sqlx.DB], error) {
+	partition, err := NewMySqlTableElementReader[T](conn, database, table)
+	if err != nil {
+		return nil, err
+	}
+	return prepareTableReader(ctx, conn, partition.(*MySqlTableElementReader[T]), options)
+}
+
+func prepareTableReader[T any](ctx context.Context, conn *sqlx.DB, reader *MySqlTableElementReader[T], options *mysqlOptions) ([]ElementPartition[DBRecord[T], *sqlx.DB], error) {
+	watermark, err := loadWatermark(ctx, conn, reader.database, reader.table, options)
+	if err != nil {
+		return nil, fmt.Errorf("loading watermark of %s.%s: %w", reader.database, reader.table, err)
+	}
+	reader.watermark = watermark
+	reader.applyReadOptions(options)
+	return splitTableReader(ctx, conn, reader, options.shard, options.split)
+}
+
+// CommitSuccess records the watermark of every table none of whose
+// partitions failed.
+func (s *MySQLSource[T]) CommitSuccess(failures []PartitionFailure) error {
+	failed := make(map[*tableWatermark]bool)
+	var watermarks []*tableWatermark
+	for _, shard := range s.shards {
+		mysqlShard, ok := shard.(*MySQLShard[T])
+		if !ok {
+			continue
+		}
+		for _, partition := range mysqlShard.partitions {
+			reader, ok := partition.(*MySqlTableElementReader[T])
+			if !ok || reader.watermark == nil {
+				continue
+			}
+			if _, seen := failed[reader.watermark]; !seen {
+				failed[reader.watermark] = false
+				watermarks = append(watermarks, reader.watermark)
+			}
+			for _, failure := range failures {
+				if failure.Shard == mysqlShard.shard && (failure.Partition == "" || failure.Partition == reader.Id()) {
+					failed[reader.watermark] = true
+				}
+			}
+		}
+	}
+	var errs []error
+	for _, watermark := range watermarks {
+		if failed[watermark] {
+			continue
+		}
+		errs = append(errs, watermark.commit())
+	}
+	return errors.Join(errs...)
 }
 
 func (s *MySQLShard[T]) Id() string {
 	return s.shard
 }
 
-func (s *MySQLShard[T]) NewResource() (Closeable, error) {
-	conn, err := sqlx.Connect("mysql", s.connUrl)
+// NewResource leases the connection pool of the shard, opened by the first
+// chunk and closed once every chunk is done with it, along with the sampling
+// connection of its throttle.
+func (s *MySQLShard[T]) NewResource() (*Lease[*sqlx.DB], error) {
+	lease, err := s.pool.Lease()
+	if err != nil || s.throttle == nil {
+		return lease, err
+	}
+	sampler, err := s.throttle.samplers.Lease()
 	if err != nil {
+		_ = lease.Close()
 		return nil, err
 	}
-	return conn, nil
+	return &Lease[*sqlx.DB]{Resource: lease.Resource, release: func() error {
+		return errors.Join(sampler.Close(), lease.Close())
+	}}, nil
 }
 
-func (s *MySQLShard[T]) Partitions() ([]ElementPartition[DBRecord[T]], error) {
+// Throttle pauses while the host is past the thresholds of WithThrottle.
+func (s *MySQLShard[T]) Throttle(ctx context.Context) (time.Duration, error) {
+	if s.throttle == nil {
+		return 0, nil
+	}
+	return s.throttle.wait(ctx)
+}
+
+func (s *MySQLShard[T]) Partitions() ([]ElementPartition[DBRecord[T], *sqlx.DB], error) {
 	return s.partitions, nil
 }
 
+func (s *MySQLShard[T]) ConsumeWith(ctx context.Context, worker *ShardWorker[DBRecord[T]], readParallelism int, readBatchSize int, notifyUpdateTo func(WorkerMetrics), maxBatchesPerChunk int) error {
+	return ConsumeShard[DBRecord[T], *sqlx.DB](ctx, worker, s, readParallelism, readBatchSize, notifyUpdateTo, maxBatchesPerChunk)
+}
+
 type MySqlTableElementReader[T any] struct {
 	database string
 	table    string
+	from     string
 
 	projection *sqlProjection
-	extractId  func(record *T) interface{}
-
-	isDone  bool
-	lastKey interface{}
+	scan       rowScanner[T]
+
+	// keyRange bounds the leading key column read by this reader, lower
+	// inclusive and upper exclusive; nil bounds are open.
+	keyRange keyRange
+	rangeId  int
+	// watermark is shared by the key-range readers of a table, nil unless
+	// reading incrementally.
+	watermark *tableWatermark
+	labels    Labels
+
+	snapshots      bool
+	snapshotMaxAge time.Duration
+	// snapshot is opened by the first batch when reading from snapshots.
+	snapshot *tableSnapshot
+
+	readMode ReadMode
+	stream   *sqlx.Rows
+	// streamConn is the pool connection held by stream, unless the stream
+	// reads from the snapshot connection.
+	streamConn       *sqlx.Conn
+	prefetch         chan prefetchedPage[T]
+	backgroundCtx    context.Context
+	cancelBackground context.CancelFunc
+
+	retry          RetryPolicy
+	sleep          func(context.Context, time.Duration) error
+	maxIdleConns   int
+	connectionWait time.Duration
+
+	isDone bool
+	// lastKey holds one value per key column of the last record read.
+	lastKey []interface{}
 }
 
+// sqlProjection describes the columns read into T. A record is keyed by every
+// field tagged sql:"pk", in field order, so tables keyed on (customer_id, id)
+// are paged with tuple comparisons.
 type sqlProjection struct {
-	pkIndex  int
-	pkColumn string
-	fields   []string
+	pkIndexes []int
+	pkColumns []string
+	// pkTypes are the Go types key values are decoded into when resuming.
+	pkTypes []reflect.Type
+	fields  []string
+}
+
+// rowScanner reads the current row into a record and returns its key.
+type rowScanner[T any] func(rows *sqlx.Rows) (*T, []interface{}, error)
+
+func scanStruct[T any](projection *sqlProjection) rowScanner[T] {
+	return func(rows *sqlx.Rows) (*T, []interface{}, error) {
+		record := new(T)
+		if err := rows.StructScan(record); err != nil {
+			return nil, nil, err
+		}
+		return record, projection.key(record), nil
+	}
 }
 
 func extractPkColumn[T any]() *sqlProjection {
 	var record T
 	st := reflect.TypeOf(record)
-	var (
-		fields []string
-		pkCol  string
-		pkIdx  int
-	)
+	projection := &sqlProjection{}
 
 	for i := 0; i < st.NumField(); i++ {
 		field := st.Field(i)
 		if dbTag, ok := field.Tag.Lookup("db"); ok {
 			dbTags := strings.Split(dbTag, ",")
-			if len(dbTags) <= 0 {
+			if dbTags[0] == "" || dbTags[0] == "-" {
 				continue
 			}
-			fields = append(fields, dbTags[0])
+			projection.fields = append(projection.fields, dbTags[0])
 			if strings.Contains(field.Tag.Get("sql"), "pk") {
-				pkCol = dbTags[0]
-				pkIdx = i
+				projection.pkColumns = append(projection.pkColumns, dbTags[0])
+				projection.pkIndexes = append(projection.pkIndexes, i)
+				projection.pkTypes = append(projection.pkTypes, field.Type)
 			}
 		}
 	}
-	return &sqlProjection{
-		pkColumn: pkCol,
-		pkIndex:  pkIdx,
-		fields:   fields,
+	return projection
+}
+
+func (p *sqlProjection) key(record interface{}) []interface{} {
+	value := reflect.ValueOf(record).Elem()
+	key := make([]interface{}, 0, len(p.pkIndexes))
+	for _, index := range p.pkIndexes {
+		key = append(key, value.Field(index).Interface())
 	}
+	return key
 }
 
-func NewMySqlTableElementReader[T any](conn *sqlx.DB, database string, table string) (ElementPartition[DBRecord[T]], error) {
-	projection := extractPkColumn[T]()
+// id is the record key itself for single column keys and the whole tuple otherwise.
+func (p *sqlProjection) id(key []interface{}) any {
+	if len(key) == 1 {
+		return key[0]
+	}
+	return key
+}
+
+func (p *sqlProjection) keyColumns() string {
+	return quoteColumns(p.pkColumns)
+}
 
-	extractId := func(record *T) any {
-		value := reflect.ValueOf(record).Elem()
-		return value.FieldByIndex([]int{projection.pkIndex}).Interface()
+func quoteColumns(names []string) string {
+	columns := make([]string, 0, len(names))
+	for _, column := range names {
+		columns = append(columns, quoteIdentifier(column))
 	}
-	row, err := conn.Queryx(fmt.Sprintf("SELECT %s as count FROM %s.%s LIMIT 1", projection.pkColumn, database, table))
+	return strings.Join(columns, ",")
+}
+
+// NewMySqlTableElementReader reads table into T, failing with a SchemaError
+// when the db and sql:"pk" tags of T do not match the table.
+func NewMySqlTableElementReader[T any](conn *sqlx.DB, database string, table string) (ElementPartition[DBRecord[T], *sqlx.DB], error) {
+	if err := validateTableSchema[T](context.Background(), conn, database, table); err != nil {
+		return nil, err
+	}
+	projection := extractPkColumn[T]()
+	return newMySqlTableElementReader(conn, database, table, tableFrom(database, table), projection, scanStruct[T](projection))
+}
+
+var ErrEmptyTable = errors.New("table is empty")
+
+// ErrKeyRangeChanged is returned when resuming a key range whose bounds
+// differ from the checkpointed run, e.g. as the table grew; the run can only
+// be started afresh.
+var ErrKeyRangeChanged = errors.New("key range changed since the checkpoint")
+
+func tableFrom(database, table string) string {
+	return quoteIdentifier(database) + "." + quoteIdentifier(table)
+}
+
+// quoteIdentifier quotes a database, table or column name, doubling the
+// backticks it contains.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// newMySqlTableElementReader reads the rows selected by from, a table or a
+// derived table, keyset paging on the key columns of projection.
+func newMySqlTableElementReader[T any](conn *sqlx.DB, database, table, from string, projection *sqlProjection, scan rowScanner[T]) (*MySqlTableElementReader[T], error) {
+	row, err := conn.Queryx(fmt.Sprintf("SELECT %s FROM %s LIMIT 1", projection.keyColumns(), from))
 	if err != nil {
 		return nil, err
 	}
+	defer row.Close()
 	if !row.Next() {
-		return nil, fmt.Errorf("Table %s.%s is Empty", database, table)
+		return nil, fmt.Errorf("%s.%s: %w", database, table, ErrEmptyTable)
 	}
 
 	return &MySqlTableElementReader[T]{
 		database: database,
 		table:    table,
+		from:     from,
 
 		projection: projection,
-		extractId:  extractId,
+		scan:       scan,
 
 		isDone:  false,
 		lastKey: nil,
 	}, nil
 }
 
+// NewMySqlTableKeyRangeReaders returns one reader per key range of the table,
+// or a single reader covering the whole table when it is not split. Tables
+// with a composite key are split on their leading key column. The boundaries
+// kept in split.Checkpoints are recorded under no shard.
+func NewMySqlTableKeyRangeReaders[T any](ctx context.Context, conn *sqlx.DB, database, table string, split KeyRangeSplit) ([]ElementPartition[DBRecord[T], *sqlx.DB], error) {
+	partition, err := NewMySqlTableElementReader[T](conn, database, table)
+	if err != nil {
+		return nil, err
+	}
+	return splitTableReader(ctx, conn, partition.(*MySqlTableElementReader[T]), "", split)
+}
+
+func splitTableReader[T any](ctx context.Context, conn *sqlx.DB, reader *MySqlTableElementReader[T], shard string, split KeyRangeSplit) ([]ElementPartition[DBRecord[T], *sqlx.DB], error) {
+	projection := reader.projection
+	ranges, err := splitKeyRange(ctx, conn, shard, reader.database, reader.table, projection.pkColumns[0], projection.pkTypes[0], split)
+	if err != nil {
+		return nil, fmt.Errorf("splitting %s.%s: %w", reader.database, reader.table, err)
+	}
+	if len(ranges) == 1 {
+		return []ElementPartition[DBRecord[T], *sqlx.DB]{reader}, nil
+	}
+	var readers []ElementPartition[DBRecord[T], *sqlx.DB]
+	for i, keyRange := range ranges {
+		rangeReader := *reader
+		rangeReader.keyRange = keyRange
+		rangeReader.rangeId = i + 1
+		readers = append(readers, &rangeReader)
+	}
+	return readers, nil
+}
+
+func (r *MySqlTableElementReader[T]) applyReadOptions(options *mysqlOptions) {
+	r.snapshots = options.snapshots
+	r.snapshotMaxAge = options.snapshotMaxAge
+	r.readMode = options.readMode
+	r.retry = options.retry
+	r.sleep = sleepContext
+	r.maxIdleConns = options.pool.MaxIdle
+	r.connectionWait = options.pool.WaitTimeout
+}
+
 func (r *MySqlTableElementReader[T]) Id() string {
+	if r.rangeId > 0 {
+		return fmt.Sprintf("%s.%s#%d", r.database, r.table, r.rangeId)
+	}
 	return fmt.Sprintf("%s.%s", r.database, r.table)
 }
 
@@ -179,41 +579,205 @@ func (r *MySqlTableElementReader[T]) Done() bool {
 	return r.isDone
 }
 
-func (r *MySqlTableElementReader[T]) NextBatch(resource interface{}, batchSize int) ([]*DBRecord[T], interface{}, error) {
+// Exclusive reports whether the reader holds a connection between batches,
+// to stream its rows or keep its snapshot open.
+func (r *MySqlTableElementReader[T]) Exclusive() bool {
+	return r.readMode == ReadStreaming || r.snapshots
+}
+
+func (r *MySqlTableElementReader[T]) NextBatch(db *sqlx.DB, batchSize int) ([]*DBRecord[T], interface{}, error) {
+	return r.NextBatchContext(context.Background(), db, batchSize)
+}
+
+// NextBatchContext reports the key of the last record read as the batch
+// offset, as a JSON array with one value per key column.
+func (r *MySqlTableElementReader[T]) NextBatchContext(ctx context.Context, db *sqlx.DB, batchSize int) ([]*DBRecord[T], interface{}, error) {
 	if r.isDone {
-		return nil, r.lastKey, nil
+		return nil, r.offset(), nil
+	}
+	var (
+		records []*T
+		keys    [][]interface{}
+		err     error
+	)
+	for attempt := 1; ; attempt++ {
+		records, keys, err = r.read(ctx, db, batchSize)
+		if err == nil || errors.Is(err, ErrSnapshotTooOld) || ctx.Err() != nil || !r.retry.retryable(err) {
+			break
+		}
+		if r.snapshot != nil && isLostConnection(err) {
+			err = fmt.Errorf("reading %s: %w: %w", r.Id(), ErrSnapshotLost, err)
+			break
+		}
+		if attempt >= r.retry.MaxAttempts {
+			if attempt > 1 {
+				err = fmt.Errorf("reading %s failed %d times: %w", r.Id(), attempt, err)
+			}
+			break
+		}
+		r.reconnect(db, err)
+		if sleepErr := r.sleep(ctx, r.retry.Backoff(attempt)); sleepErr != nil {
+			break
+		}
 	}
-	conn := resource.(*sqlx.DB)
-	records, err := readMySQlTableInBatch[T](conn, r.database, r.table, r.projection.pkColumn, r.projection.fields, batchSize, r.lastKey)
 	if err != nil {
-		return nil, r.lastKey, err
+		if r.snapshot != nil || r.stream != nil {
+			// release the connection rather than leave it to a failed partition
+			err = errors.Join(err, r.Close())
+		}
+		return nil, r.offset(), err
 	}
 
 	var dbRecords []*DBRecord[T]
-	for _, record := range records {
+	for i, record := range records {
+		key := keys[i]
 		dbRecords = append(dbRecords, &DBRecord[T]{
 			DataBase: r.database,
 			Table:    r.table,
-			Id:       r.extractId(record),
+			Id:       r.projection.id(key),
 			Record:   record,
+			Labels:   r.labels,
 		})
+		r.lastKey = key
 	}
 
-	if len(dbRecords) > 0 {
-		r.lastKey = dbRecords[len(dbRecords)-1].Id
-	}
 	if len(dbRecords) < batchSize {
 		r.isDone = true
-		return dbRecords, r.lastKey, r.Close()
+		return dbRecords, r.offset(), r.Close()
 	}
-	return dbRecords, r.lastKey, nil
+	return dbRecords, r.offset(), nil
 }
 
-func (r *MySqlTableElementReader[T]) Close() error {
+// read reads the batch after lastKey, from the snapshot of the reader when
+// reading from snapshots.
+func (r *MySqlTableElementReader[T]) read(ctx context.Context, db *sqlx.DB, batchSize int) ([]*T, [][]interface{}, error) {
+	var conn sqlx.QueryerContext = db
+	if r.snapshots {
+		if r.snapshot == nil {
+			snapshot, err := openSnapshot(ctx, db, r.snapshotMaxAge, r.connectionWait)
+			if err != nil {
+				return nil, nil, err
+			}
+			r.snapshot = snapshot
+		}
+		if err := r.snapshot.check(); err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", r.Id(), err)
+		}
+		conn = r.snapshot.conn
+	}
+	switch r.readMode {
+	case ReadStreaming:
+		return r.readStream(ctx, conn, batchSize)
+	case ReadPrefetch:
+		return r.readPrefetched(ctx, conn, batchSize)
+	default:
+		return readMySQlTableInBatch(ctx, conn, r.from, r.projection, r.scan, batchSize, r.lastKey, r.keyRange, r.watermark)
+	}
+}
+
+// Resume accepts the offsets reported by NextBatchContext, as well as a bare
+// key value for single column keys. The reader of a key range only resumes
+// from an offset recorded for the same range.
+func (r *MySqlTableElementReader[T]) Resume(offset json.RawMessage) error {
+	if r.rangeId > 0 {
+		var ranged struct {
+			Key json.RawMessage `json:"key"`
+		}
+		if err := r.CheckOffset(offset); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(offset, &ranged); err != nil {
+			return err
+		}
+		if len(ranged.Key) == 0 || string(ranged.Key) == "null" {
+			return nil
+		}
+		offset = ranged.Key
+	}
+	types := r.projection.pkTypes
+	var values []json.RawMessage
+	if err := json.Unmarshal(offset, &values); err != nil {
+		if len(types) > 1 {
+			return err
+		}
+		values = []json.RawMessage{offset}
+	}
+	if len(values) != len(types) {
+		return fmt.Errorf("offset %s does not match the %d key columns of %s", offset, len(types), r.Id())
+	}
+	key := make([]interface{}, 0, len(types))
+	for i, value := range values {
+		column := reflect.New(types[i])
+		if err := json.Unmarshal(value, column.Interface()); err != nil {
+			return err
+		}
+		key = append(key, column.Elem().Interface())
+	}
+	r.lastKey = key
 	return nil
 }
 
-func scanDatabases(conn *sqlx.DB, dbMatching string) ([]string, error) {
+// rangeOffset is the offset of the reader of a key range, which only holds
+// for the bounds of the range.
+type rangeOffset struct {
+	Key   interface{}   `json:"key"`
+	Range []interface{} `json:"range"`
+}
+
+// offset reports the last key read, along with the bounds of the key range
+// when the table is split, as a later run may split it differently.
+func (r *MySqlTableElementReader[T]) offset() interface{} {
+	if r.rangeId == 0 {
+		return r.lastKey
+	}
+	return rangeOffset{Key: r.lastKey, Range: []interface{}{r.keyRange.lower, r.keyRange.upper}}
+}
+
+// CheckOffset rejects the offsets of a key range recorded with other bounds
+// than the range of the reader.
+func (r *MySqlTableElementReader[T]) CheckOffset(offset json.RawMessage) error {
+	if r.rangeId == 0 {
+		return nil
+	}
+	var ranged struct {
+		Range json.RawMessage `json:"range"`
+	}
+	if err := json.Unmarshal(offset, &ranged); err != nil || len(ranged.Range) == 0 {
+		return fmt.Errorf("offset %s of %s does not record its key range", offset, r.Id())
+	}
+	current, err := json.Marshal([]interface{}{r.keyRange.lower, r.keyRange.upper})
+	if err != nil {
+		return err
+	}
+	var recorded bytes.Buffer
+	if err := json.Compact(&recorded, ranged.Range); err != nil {
+		return err
+	}
+	if !bytes.Equal(recorded.Bytes(), current) {
+		return fmt.Errorf("%w: %s was read as %s and is now %s", ErrKeyRangeChanged, r.Id(), recorded.Bytes(), current)
+	}
+	return nil
+}
+
+func (r *MySqlTableElementReader[T]) Close() error {
+	readsErr := r.closeReads()
+	if r.snapshot == nil {
+		return readsErr
+	}
+	snapshot := r.snapshot
+	r.snapshot = nil
+	return errors.Join(readsErr, snapshot.Close())
+}
+
+type matchedDatabase struct {
+	name   string
+	labels Labels
+}
+
+// scanDatabases returns the databases matching dbMatching with the values of
+// its named groups, e.g. production_env(?P<env_id>\d+) labels every record
+// read from production_env12 with env_id 12.
+func scanDatabases(conn *sqlx.DB, dbMatching string) ([]matchedDatabase, error) {
 	var databases []string
 	err := conn.Select(&databases, "SHOW DATABASES")
 	if err != nil {
@@ -223,40 +787,99 @@ func scanDatabases(conn *sqlx.DB, dbMatching string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	var filtered []string
+	var filtered []matchedDatabase
 	for _, db := range databases {
 		if dbPattern.MatchString(db) {
-			filtered = append(filtered, db)
+			filtered = append(filtered, matchedDatabase{name: db, labels: captureLabels(dbPattern, db)})
 		}
 	}
 	return filtered, nil
 }
 
 func readMySQlTableInBatch[T any](
-	conn *sqlx.DB,
-	database string,
-	table string,
-	pkCol string,
-	fields []string,
+	ctx context.Context,
+	conn sqlx.QueryerContext,
+	from string,
+	projection *sqlProjection,
+	scan rowScanner[T],
 	limit int,
-	lastKey interface{},
-) ([]*T, error) {
+	lastKey []interface{},
+	keyRange keyRange,
+	watermark *tableWatermark,
+) ([]*T, [][]interface{}, error) {
+	query, args := batchQuery(from, projection, limit, lastKey, keyRange, watermark)
+	rows, err := conn.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	records, keys, _, err := scanRows(ctx, rows, scan, limit)
+	return records, keys, err
+}
+
+// batchQuery selects the rows after lastKey in key order, all of them when
+// limit is 0.
+func batchQuery(
+	from string,
+	projection *sqlProjection,
+	limit int,
+	lastKey []interface{},
+	keyRange keyRange,
+	watermark *tableWatermark,
+) (string, []interface{}) {
 
 	var (
-		records []*T
-		err     error
+		conditions []string
+		args       []interface{}
 	)
 
-	if lastKey == nil {
-		query := fmt.Sprintf("SELECT %s FROM `%s`.`%s` LIMIT %d ", strings.Join(fields, ","), database, table, limit)
-		err = conn.Select(&records, query)
+	leading := quoteIdentifier(projection.pkColumns[0])
+	if lastKey != nil {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(lastKey)), ",")
+		conditions = append(conditions, fmt.Sprintf("(%s) > (%s)", projection.keyColumns(), placeholders))
+		args = append(args, lastKey...)
+	} else if keyRange.lower != nil {
+		conditions = append(conditions, fmt.Sprintf("%s >= ?", leading))
+		args = append(args, keyRange.lower)
+	}
+	if keyRange.upper != nil {
+		conditions = append(conditions, fmt.Sprintf("%s < ?", leading))
+		args = append(args, keyRange.upper)
+	}
+	watermarkConditions, watermarkArgs := watermark.conditions()
+	conditions = append(conditions, watermarkConditions...)
+	args = append(args, watermarkArgs...)
 
-	} else {
-		query := fmt.Sprintf("SELECT %s FROM `%s`.`%s` WHERE %s > ? LIMIT %d ", strings.Join(fields, ","), database, table, pkCol, limit)
-		err = conn.Select(&records, query, lastKey)
+	query := fmt.Sprintf("SELECT %s FROM %s", quoteColumns(projection.fields), from)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
-	if err != nil {
-		return nil, err
+	query += fmt.Sprintf(" ORDER BY %s", projection.keyColumns())
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d ", limit)
+	}
+	return query, args
+}
+
+// scanRows reads up to limit rows and reports whether rows is exhausted.
+func scanRows[T any](ctx context.Context, rows *sqlx.Rows, scan rowScanner[T], limit int) ([]*T, [][]interface{}, bool, error) {
+	var (
+		records []*T
+		keys    [][]interface{}
+	)
+	for len(records) < limit {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, false, err
+		}
+		if !rows.Next() {
+			return records, keys, true, rows.Err()
+		}
+		record, key, err := scan(rows)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		records = append(records, record)
+		keys = append(keys, key)
 	}
-	return records, nil
+	return records, keys, false, nil
 }
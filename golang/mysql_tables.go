@@ -0,0 +1,99 @@
+package etl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type tableDateRange struct {
+	from time.Time
+	to   time.Time
+}
+
+// WithTableDateRange expands the {yyyy}, {yy}, {MM} and {dd} placeholders of
+// the table name into every table from from to to, both included, so
+// delivs_{yyyy}_{MM} from 2024-06 to 2024-11 reads the six monthly tables
+// that exist in each database.
+func WithTableDateRange(from, to time.Time) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.tableRange = &tableDateRange{from: from, to: to}
+	}
+}
+
+// expandTableTemplate returns the table names of template between from and
+// to, stepping by the finest placeholder it contains.
+func expandTableTemplate(template string, from, to time.Time) ([]string, error) {
+	if !strings.Contains(template, "{") {
+		return nil, fmt.Errorf("table %s has no date placeholder", template)
+	}
+	format := func(date time.Time) string {
+		return strings.NewReplacer(
+			"{yyyy}", fmt.Sprintf("%04d", date.Year()),
+			"{yy}", fmt.Sprintf("%02d", date.Year()%100),
+			"{MM}", fmt.Sprintf("%02d", date.Month()),
+			"{dd}", fmt.Sprintf("%02d", date.Day()),
+		).Replace(template)
+	}
+	if strings.Contains(format(from), "{") {
+		return nil, fmt.Errorf("table %s has an unknown placeholder", template)
+	}
+	years, months, days := 1, 0, 0
+	start := time.Date(from.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	switch {
+	case strings.Contains(template, "{dd}"):
+		years, days = 0, 1
+		start = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	case strings.Contains(template, "{MM}"):
+		years, months = 0, 1
+		start = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	var tables []string
+	for date := start; !date.After(to); date = date.AddDate(years, months, days) {
+		tables = append(tables, format(date))
+	}
+	return tables, nil
+}
+
+// tableMatcher matches table names against the table argument of the
+// source: a date template when a range is set, a regular expression matching
+// the whole name otherwise, so a plain name matches only itself.
+func tableMatcher(table string, options *mysqlOptions) (func(string) bool, error) {
+	if options.tableRange != nil {
+		tables, err := expandTableTemplate(table, options.tableRange.from, options.tableRange.to)
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[string]bool, len(tables))
+		for _, name := range tables {
+			names[name] = true
+		}
+		return func(name string) bool { return names[name] }, nil
+	}
+	pattern, err := regexp.Compile("^(?:" + table + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return pattern.MatchString, nil
+}
+
+func scanTables(conn *sqlx.DB, database string, matches func(string) bool) ([]string, error) {
+	var tables []string
+	err := conn.Select(&tables,
+		"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME",
+		database)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []string
+	for _, table := range tables {
+		if matches(table) {
+			filtered = append(filtered, table)
+		}
+	}
+	return filtered, nil
+}
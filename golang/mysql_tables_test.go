@@ -0,0 +1,36 @@
+package etl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableMatching(t *testing.T) {
+	t.Run("TestExpandMonthlyTemplate", func(t *testing.T) {
+		from := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+		tables, err := expandTableTemplate("delivs_{yyyy}_{MM}", from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"delivs_2024_06", "delivs_2024_07", "delivs_2024_08", "delivs_2024_09", "delivs_2024_10", "delivs_2024_11"}, tables)
+
+		tables, err = expandTableTemplate("v1_{yy}{MM}{dd}", to, to.AddDate(0, 0, 1))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1_241101", "v1_241102"}, tables)
+
+		_, err = expandTableTemplate("delivs_{week}", from, to)
+		assert.Error(t, err)
+	})
+
+	t.Run("TestPatternMatchesWholeName", func(t *testing.T) {
+		matches, err := tableMatcher("delivs_2024_11", &mysqlOptions{})
+		assert.NoError(t, err)
+		assert.True(t, matches("delivs_2024_11"))
+		assert.False(t, matches("delivs_2024_11_old"))
+
+		matches, err = tableMatcher(`delivs_2024_\d+`, &mysqlOptions{})
+		assert.NoError(t, err)
+		assert.True(t, matches("delivs_2024_10"))
+	})
+}
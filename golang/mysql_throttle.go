@@ -0,0 +1,239 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Throttle pauses the reads of a host while it is under load, sampling it at
+// most once per Interval. Thresholds left at 0 are not checked. A sample that
+// fails pauses reads too, as the load of the host is unknown.
+type Throttle struct {
+	// MaxThreadsRunning is the highest Threads_running reads go on at.
+	MaxThreadsRunning int
+	// MaxReplicaLag is the highest replication lag reads go on at, as reported
+	// by SHOW REPLICA STATUS, or by LagQuery when set. A host that is not a
+	// replica has no lag, and one whose replication is stopped, reporting a
+	// NULL lag, lags until it is restarted.
+	MaxReplicaLag time.Duration
+	// LagQuery returns the lag in seconds as a single value, such as a query
+	// on a heartbeat table.
+	LagQuery string
+	// Interval defaults to a second.
+	Interval time.Duration
+	// FailAfter bounds how long samples may keep failing before the reads
+	// waiting on them fail; a minute by default.
+	FailAfter time.Duration
+}
+
+// WithThrottle pauses the reads of every shard while its host is past the
+// thresholds of throttle.
+func WithThrottle(throttle Throttle) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.throttle = &throttle
+	}
+}
+
+func (t Throttle) interval() time.Duration {
+	if t.Interval <= 0 {
+		return time.Second
+	}
+	return t.Interval
+}
+
+func (t Throttle) failAfter() time.Duration {
+	if t.FailAfter <= 0 {
+		return time.Minute
+	}
+	return t.FailAfter
+}
+
+// replicationStopped is the lag of a replica whose replication is stopped.
+const replicationStopped = time.Duration(math.MaxInt64)
+
+type serverLoad struct {
+	threadsRunning int
+	replicaLag     time.Duration
+}
+
+func (t Throttle) exceeded(load serverLoad) bool {
+	return (t.MaxThreadsRunning > 0 && load.threadsRunning > t.MaxThreadsRunning) ||
+		(t.MaxReplicaLag > 0 && load.replicaLag > t.MaxReplicaLag)
+}
+
+// throttleSampleTimeout bounds a sample, which then counts as failed.
+const throttleSampleTimeout = 5 * time.Second
+
+// hostThrottle shares the samples of a host between the chunks reading it.
+type hostThrottle struct {
+	config Throttle
+	// samplers is a connection of its own, leased by the shard while it is
+	// read, as partitions may hold every connection of the read pool.
+	samplers *SharedResource[*sqlx.DB]
+	sample   func(ctx context.Context) (serverLoad, error)
+	sleep    func(context.Context, time.Duration) error
+
+	mu        sync.Mutex
+	sampled   time.Time
+	sampling  bool
+	throttled bool
+	// failingSince is when samples started failing, zero while they succeed
+	failingSince time.Time
+}
+
+func newHostThrottle(config Throttle, samplers *SharedResource[*sqlx.DB]) *hostThrottle {
+	return &hostThrottle{
+		config:   config,
+		samplers: samplers,
+		sample: func(ctx context.Context) (serverLoad, error) {
+			lease, err := samplers.Lease()
+			if err != nil {
+				return serverLoad{}, err
+			}
+			defer lease.Close()
+			return sampleServerLoad(ctx, lease.Resource, config)
+		},
+		sleep: sleepContext,
+	}
+}
+
+// wait blocks until the host is no longer past the thresholds and returns
+// how long it paused. It fails once samples kept failing for FailAfter.
+func (t *hostThrottle) wait(ctx context.Context) (time.Duration, error) {
+	var started time.Time
+	paused := func() time.Duration {
+		if started.IsZero() {
+			return 0
+		}
+		return time.Since(started)
+	}
+	for {
+		throttled, err := t.check(ctx)
+		if err != nil || !throttled {
+			return paused(), err
+		}
+		if started.IsZero() {
+			started = time.Now()
+		}
+		if err := t.sleep(ctx, t.config.interval()); err != nil {
+			return paused(), err
+		}
+	}
+}
+
+// check samples the host when the last sample is older than the interval.
+// Chunks checking while another one samples go by the previous sample.
+func (t *hostThrottle) check(ctx context.Context) (bool, error) {
+	t.mu.Lock()
+	if t.sampling || time.Since(t.sampled) < t.config.interval() {
+		throttled := t.throttled
+		t.mu.Unlock()
+		return throttled, nil
+	}
+	t.sampling = true
+	t.mu.Unlock()
+
+	sampleCtx, cancel := context.WithTimeout(ctx, throttleSampleTimeout)
+	load, err := t.sample(sampleCtx)
+	cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sampling = false
+	t.sampled = time.Now()
+	if err != nil {
+		t.throttled = true
+		if t.failingSince.IsZero() {
+			t.failingSince = t.sampled
+		}
+		if failing := t.sampled.Sub(t.failingSince); failing >= t.config.failAfter() {
+			return true, fmt.Errorf("sampling the load of the host failed for %s: %w", failing.Round(time.Second), err)
+		}
+		return true, nil
+	}
+	t.failingSince = time.Time{}
+	t.throttled = t.config.exceeded(load)
+	return t.throttled, nil
+}
+
+func sampleServerLoad(ctx context.Context, db *sqlx.DB, config Throttle) (serverLoad, error) {
+	var load serverLoad
+	if config.MaxThreadsRunning > 0 {
+		var name string
+		err := db.QueryRowxContext(ctx, "SHOW GLOBAL STATUS LIKE 'Threads_running'").Scan(&name, &load.threadsRunning)
+		if err != nil {
+			return load, err
+		}
+	}
+	if config.MaxReplicaLag > 0 {
+		lag, err := replicaLag(ctx, db, config.LagQuery)
+		if err != nil {
+			return load, err
+		}
+		load.replicaLag = lag
+	}
+	return load, nil
+}
+
+func replicaLag(ctx context.Context, db *sqlx.DB, lagQuery string) (time.Duration, error) {
+	if lagQuery != "" {
+		var seconds sql.NullFloat64
+		if err := db.QueryRowxContext(ctx, lagQuery).Scan(&seconds); err != nil {
+			return 0, err
+		}
+		if !seconds.Valid {
+			return replicationStopped, nil
+		}
+		return time.Duration(seconds.Float64 * float64(time.Second)), nil
+	}
+	status, err := replicaStatus(ctx, db, "SHOW REPLICA STATUS")
+	if err != nil {
+		// servers before 8.0.22
+		status, err = replicaStatus(ctx, db, "SHOW SLAVE STATUS")
+	}
+	if err != nil || status == nil {
+		return 0, err
+	}
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		value, ok := status[column]
+		if !ok {
+			continue
+		}
+		if value == nil {
+			return replicationStopped, nil
+		}
+		if raw, ok := value.([]byte); ok {
+			value = string(raw)
+		}
+		seconds, err := strconv.Atoi(fmt.Sprint(value))
+		if err != nil {
+			return 0, fmt.Errorf("reading %s: %w", column, err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("replica status reports no Seconds_Behind_Source")
+}
+
+func replicaStatus(ctx context.Context, db *sqlx.DB, query string) (map[string]interface{}, error) {
+	rows, err := db.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	status := make(map[string]interface{})
+	if err := rows.MapScan(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -0,0 +1,172 @@
+package etl
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestThrottle(t *testing.T) {
+	newThrottle := func(loads ...serverLoad) (*hostThrottle, *int) {
+		samples := 0
+		throttle := &hostThrottle{
+			config: Throttle{MaxThreadsRunning: 50, MaxReplicaLag: 10 * time.Second, Interval: time.Nanosecond},
+			sample: func(ctx context.Context) (serverLoad, error) {
+				load := loads[min(samples, len(loads)-1)]
+				samples++
+				return load, nil
+			},
+			sleep: func(ctx context.Context, d time.Duration) error {
+				time.Sleep(time.Millisecond)
+				return ctx.Err()
+			},
+		}
+		return throttle, &samples
+	}
+
+	t.Run("TestPausesUntilUnderThresholds", func(t *testing.T) {
+		throttle, samples := newThrottle(
+			serverLoad{threadsRunning: 80},
+			serverLoad{threadsRunning: 10, replicaLag: time.Minute},
+			serverLoad{threadsRunning: 10, replicaLag: time.Second},
+		)
+		paused, err := throttle.wait(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 3, *samples)
+		assert.GreaterOrEqual(t, paused, 2*time.Millisecond)
+
+		paused, err = throttle.wait(context.Background())
+		assert.NoError(t, err)
+		assert.Zero(t, paused, "no pause under the thresholds")
+	})
+
+	t.Run("TestSharesSamplesWithinInterval", func(t *testing.T) {
+		throttle, samples := newThrottle(serverLoad{threadsRunning: 10})
+		throttle.config.Interval = time.Hour
+		for range 3 {
+			_, err := throttle.wait(context.Background())
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, *samples)
+	})
+
+	t.Run("TestStopsWithContext", func(t *testing.T) {
+		throttle, _ := newThrottle(serverLoad{threadsRunning: 80})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := throttle.wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("TestFailedSamplesPauseThenFail", func(t *testing.T) {
+		throttle, samples := newThrottle(serverLoad{})
+		throttle.config.FailAfter = 5 * time.Millisecond
+		throttle.sample = func(ctx context.Context) (serverLoad, error) {
+			*samples++
+			return serverLoad{}, errors.New("access denied")
+		}
+		paused, err := throttle.wait(context.Background())
+		assert.ErrorContains(t, err, "access denied")
+		assert.Greater(t, *samples, 1, "a failed sample pauses until the next")
+		assert.GreaterOrEqual(t, paused, 5*time.Millisecond)
+	})
+
+	t.Run("TestRecoveredSamplesResetFailures", func(t *testing.T) {
+		throttle, samples := newThrottle(serverLoad{})
+		throttle.config.FailAfter = time.Hour
+		throttle.sample = func(ctx context.Context) (serverLoad, error) {
+			*samples++
+			if *samples == 1 {
+				return serverLoad{}, errors.New("timeout")
+			}
+			return serverLoad{}, nil
+		}
+		paused, err := throttle.wait(context.Background())
+		assert.NoError(t, err)
+		assert.Greater(t, paused, time.Duration(0))
+		assert.True(t, throttle.failingSince.IsZero())
+	})
+}
+
+func TestReplicaLag(t *testing.T) {
+	lagOf := func(t *testing.T, status fakeResult, lagQuery string) (time.Duration, error) {
+		server := &fakeServer{respond: func(query string, args []driver.NamedValue) fakeResult {
+			return status
+		}}
+		db := server.open(1)
+		defer db.Close()
+		return replicaLag(context.Background(), db, lagQuery)
+	}
+
+	t.Run("TestReadsSecondsBehind", func(t *testing.T) {
+		lag, err := lagOf(t, fakeResult{columns: []string{"Seconds_Behind_Source"}, rows: [][]driver.Value{{[]byte("42")}}}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, 42*time.Second, lag)
+	})
+
+	t.Run("TestStoppedReplicationLags", func(t *testing.T) {
+		lag, err := lagOf(t, fakeResult{columns: []string{"Seconds_Behind_Source"}, rows: [][]driver.Value{{nil}}}, "")
+		assert.NoError(t, err)
+		assert.True(t, Throttle{MaxReplicaLag: time.Hour}.exceeded(serverLoad{replicaLag: lag}))
+
+		lag, err = lagOf(t, fakeResult{columns: []string{"lag"}, rows: [][]driver.Value{{nil}}}, "SELECT lag FROM heartbeat")
+		assert.NoError(t, err)
+		assert.Equal(t, replicationStopped, lag)
+	})
+
+	t.Run("TestNotAReplica", func(t *testing.T) {
+		lag, err := lagOf(t, fakeResult{columns: []string{"Seconds_Behind_Source"}}, "")
+		assert.NoError(t, err)
+		assert.Zero(t, lag)
+	})
+
+	t.Run("TestFailedStatusIsAnError", func(t *testing.T) {
+		_, err := lagOf(t, fakeResult{err: errors.New("access denied")}, "")
+		assert.ErrorContains(t, err, "access denied")
+	})
+}
+
+func TestThrottleWithStreaming(t *testing.T) {
+	server := &fakeServer{respond: func(query string, args []driver.NamedValue) fakeResult {
+		if strings.HasPrefix(query, "SHOW GLOBAL STATUS") {
+			return fakeResult{columns: []string{"Variable_name", "Value"}, rows: [][]driver.Value{{"Threads_running", int64(1)}}}
+		}
+		return fakeTableRows(6, query, args)
+	}}
+	reader := newFakeReader(t, server.open(1), newMySQLOptions([]MySQLOption{WithReadMode(ReadStreaming)}))
+	// the stream of the partition holds the only connection of the read pool
+	// while the throttle samples before every batch
+	shard := &MySQLShard[fakeRecord]{
+		shard:      "s",
+		pool:       NewSharedResource(func() (*sqlx.DB, error) { return server.open(1), nil }),
+		partitions: []ElementPartition[DBRecord[fakeRecord], *sqlx.DB]{reader},
+		throttle: newHostThrottle(Throttle{MaxThreadsRunning: 10, Interval: time.Nanosecond}, NewSharedResource(func() (*sqlx.DB, error) {
+			return server.open(1), nil
+		})),
+	}
+
+	worker := NewShardWorker[DBRecord[fakeRecord]]("s", 10, zap.NewNop())
+	done := make(chan error)
+	go func() {
+		done <- worker.Consume(context.Background(), shard, 1, 2, func(WorkerMetrics) {}, 0)
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reads blocked on the throttle")
+	}
+	read := 0
+	for batch := range worker.buffer {
+		read += len(batch.Records)
+	}
+	assert.Equal(t, 6, read)
+	assert.Contains(t, server.statements, "SHOW GLOBAL STATUS LIKE 'Threads_running'")
+}
@@ -0,0 +1,213 @@
+package etl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Watermark is the high-water mark of a table. Mark is the highest value read
+// by the last successful run and Pending the one the current run reads up to;
+// an interrupted run leaves Pending set so that resuming it reads the same rows.
+type Watermark struct {
+	Mark    json.RawMessage `json:"mark,omitempty"`
+	Pending json.RawMessage `json:"pending,omitempty"`
+}
+
+type WatermarkStore interface {
+	Load(database, table string) (*Watermark, error)
+	Save(database, table string, watermark Watermark) error
+}
+
+// WithWatermark only reads the rows whose column is at or above the mark of
+// the last successful run, and records the new mark once the run succeeds.
+// Rows at the mark are read again, as rows written after a run with the
+// value it stopped at, such as an updated_at in the same second, would be
+// missed otherwise; their key tells the duplicates apart. The column should be
+// indexed and increase on every change, like an updated_at or an
+// auto-increment id.
+func WithWatermark(column string, store WatermarkStore) MySQLOption {
+	return func(o *mysqlOptions) {
+		o.watermarkColumn = column
+		o.watermarks = store
+	}
+}
+
+// SuccessCommitter is implemented by sources keeping state across runs. The
+// pipeline calls CommitSuccess after a run that was not interrupted, with the
+// failures of the run, so that failed partitions are read again next time.
+type SuccessCommitter interface {
+	CommitSuccess(failures []PartitionFailure) error
+}
+
+type tableWatermark struct {
+	database string
+	table    string
+	column   string
+	store    WatermarkStore
+
+	since interface{}
+	until interface{}
+	mark  json.RawMessage
+	// pending is until as saved in the store
+	pending json.RawMessage
+}
+
+func loadWatermark(ctx context.Context, conn *sqlx.DB, database, table string, options *mysqlOptions) (*tableWatermark, error) {
+	if options.watermarkColumn == "" {
+		return nil, nil
+	}
+	saved, err := options.watermarks.Load(database, table)
+	if err != nil {
+		return nil, err
+	}
+	if saved == nil {
+		saved = &Watermark{}
+	}
+	watermark := &tableWatermark{
+		database: database,
+		table:    table,
+		column:   options.watermarkColumn,
+		store:    options.watermarks,
+		mark:     saved.Mark,
+		pending:  saved.Pending,
+	}
+	if watermark.since, err = decodeWatermark(saved.Mark); err != nil {
+		return nil, err
+	}
+	if len(saved.Pending) == 0 {
+		var until interface{}
+		query := fmt.Sprintf("SELECT MAX(%s) FROM %s", quoteIdentifier(watermark.column), tableFrom(database, table))
+		if err := conn.QueryRowxContext(ctx, query).Scan(&until); err != nil {
+			return nil, err
+		}
+		if watermark.pending, err = encodeWatermark(until); err != nil {
+			return nil, err
+		}
+		err = watermark.store.Save(database, table, Watermark{Mark: watermark.mark, Pending: watermark.pending})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if watermark.until, err = decodeWatermark(watermark.pending); err != nil {
+		return nil, err
+	}
+	return watermark, nil
+}
+
+func (w *tableWatermark) conditions() ([]string, []interface{}) {
+	if w == nil {
+		return nil, nil
+	}
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if w.since != nil {
+		conditions = append(conditions, quoteIdentifier(w.column)+" >= ?")
+		args = append(args, w.since)
+	}
+	if w.until != nil {
+		conditions = append(conditions, quoteIdentifier(w.column)+" <= ?")
+		args = append(args, w.until)
+	}
+	return conditions, args
+}
+
+func (w *tableWatermark) commit() error {
+	mark := w.pending
+	if len(mark) == 0 || string(mark) == "null" {
+		// the column was empty, nothing has been read past the previous mark
+		mark = w.mark
+	}
+	return w.store.Save(w.database, w.table, Watermark{Mark: mark})
+}
+
+func encodeWatermark(value interface{}) (json.RawMessage, error) {
+	switch v := value.(type) {
+	case []byte:
+		value = string(v)
+	case time.Time:
+		value = v.Format("2006-01-02 15:04:05.999999")
+	}
+	return json.Marshal(value)
+}
+
+// decodeWatermark binds integers back into queries as int64, or uint64 past
+// its range, so large ids keep every digit and compare as numbers. Other
+// numbers, such as DECIMAL values, are bound in their decimal form.
+func decodeWatermark(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if number, ok := value.(json.Number); ok {
+		if integer, err := number.Int64(); err == nil {
+			return integer, nil
+		}
+		if integer, err := strconv.ParseUint(string(number), 10, 64); err == nil {
+			return integer, nil
+		}
+		return string(number), nil
+	}
+	return value, nil
+}
+
+type fileWatermarkStore struct {
+	mu         sync.Mutex
+	path       string
+	watermarks map[string]Watermark
+}
+
+// NewFileWatermarkStore keeps the watermarks of every table in a JSON file
+// rewritten on each save.
+func NewFileWatermarkStore(path string) (WatermarkStore, error) {
+	watermarks := make(map[string]Watermark)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &watermarks); err != nil {
+			return nil, fmt.Errorf("reading watermarks from %s: %w", path, err)
+		}
+	}
+	return &fileWatermarkStore{path: path, watermarks: watermarks}, nil
+}
+
+func (s *fileWatermarkStore) Load(database, table string) (*Watermark, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watermark, ok := s.watermarks[database+"."+table]
+	if !ok {
+		return nil, nil
+	}
+	return &watermark, nil
+}
+
+func (s *fileWatermarkStore) Save(database, table string, watermark Watermark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watermarks[database+"."+table] = watermark
+	data, err := json.MarshalIndent(s.watermarks, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,71 @@
+package etl
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatermarks(t *testing.T) {
+	t.Run("TestFileWatermarkStoreReopens", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "watermarks.json")
+		store, err := NewFileWatermarkStore(path)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Save("db", "t", Watermark{Mark: json.RawMessage(`"2024-11-05 10:11:12"`)}))
+
+		reopened, err := NewFileWatermarkStore(path)
+		assert.NoError(t, err)
+		watermark, err := reopened.Load("db", "t")
+		assert.NoError(t, err)
+		assert.Equal(t, `"2024-11-05 10:11:12"`, string(watermark.Mark))
+		missing, err := reopened.Load("db", "other")
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("TestDecodeKeepsLargeIds", func(t *testing.T) {
+		value, err := decodeWatermark(json.RawMessage("18446744073709551615"))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(18446744073709551615), value)
+		value, err = decodeWatermark(json.RawMessage("9007199254740993"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(9007199254740993), value)
+		value, err = decodeWatermark(json.RawMessage("12.50"))
+		assert.NoError(t, err)
+		assert.Equal(t, "12.50", value)
+	})
+
+	t.Run("TestRereadsRowsAtTheMark", func(t *testing.T) {
+		watermark := &tableWatermark{column: "updated_at", since: "2024-11-05 10:11:12", until: "2024-11-06 00:00:00"}
+		conditions, args := watermark.conditions()
+		assert.Equal(t, []string{"`updated_at` >= ?", "`updated_at` <= ?"}, conditions)
+		assert.Equal(t, []interface{}{"2024-11-05 10:11:12", "2024-11-06 00:00:00"}, args)
+	})
+
+	t.Run("TestCommitSuccessSkipsFailedTables", func(t *testing.T) {
+		store, err := NewFileWatermarkStore(filepath.Join(t.TempDir(), "watermarks.json"))
+		assert.NoError(t, err)
+		watermark := func(database string) *tableWatermark {
+			return &tableWatermark{database: database, table: "t", column: "id", store: store, mark: json.RawMessage("1"), pending: json.RawMessage("10")}
+		}
+		healthy, broken := watermark("healthy"), watermark("broken")
+		source := &MySQLSource[customerRecord]{shards: []ElementShard[DBRecord[customerRecord]]{
+			&MySQLShard[customerRecord]{shard: "host", partitions: []ElementPartition[DBRecord[customerRecord], *sqlx.DB]{
+				&MySqlTableElementReader[customerRecord]{database: "healthy", table: "t", watermark: healthy},
+				&MySqlTableElementReader[customerRecord]{database: "broken", table: "t", watermark: broken, rangeId: 1},
+				&MySqlTableElementReader[customerRecord]{database: "broken", table: "t", watermark: broken, rangeId: 2},
+			}},
+		}}
+
+		assert.NoError(t, source.CommitSuccess([]PartitionFailure{{Shard: "host", Partition: "broken.t#2", Stage: StageRead, Err: errors.New("boom")}}))
+		committed, _ := store.Load("healthy", "t")
+		assert.Equal(t, "10", string(committed.Mark))
+		assert.Empty(t, committed.Pending)
+		notCommitted, _ := store.Load("broken", "t")
+		assert.Nil(t, notCommitted)
+	})
+}
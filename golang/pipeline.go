@@ -0,0 +1,196 @@
+package etl
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+type Pipeline[T any] struct {
+	source      ElementSource[T]
+	processor   ElementProcessor[T]
+	sinkFactory ElementWriterFactory
+	options     *executeOptions
+}
+
+func NewPipeline[T any](
+	source ElementSource[T],
+	processor ElementProcessor[T],
+	sinkFactory ElementWriterFactory,
+	opts ...ExecuteOption,
+) *Pipeline[T] {
+	return &Pipeline[T]{
+		source:      source,
+		processor:   processor,
+		sinkFactory: sinkFactory,
+		options:     newExecuteOptions(opts),
+	}
+}
+
+// Run processes every shard of the source. The result is returned even when
+// the run fails, as long as the shards of the source could be listed.
+func (p *Pipeline[T]) Run(ctx context.Context) (*RunResult, error) {
+	options, logger := p.options, p.options.logger
+	parent := ctx
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	shards, err := p.source.Shards()
+	if err != nil {
+		return nil, err
+	}
+	var replayOf string
+	if replayed, ok := p.source.(replayedSource); ok {
+		replayOf = replayed.ReplayOf()
+	}
+	logger.Info("Processing shards", zap.Int("count", len(shards)), zap.String("replayOf", replayOf))
+	results := newRunResultCollector(options.runId, p.source.Id())
+	readProgressUpdater, writeProgressUpdater := buildProgressUpdaters(len(shards)*options.readParallelism, len(shards)*options.writeParallelism)
+	writeCtx, stopWriting := drainOnCancel(ctx, options.shutdown, logger)
+	defer stopWriting()
+
+	var report FailureReport
+	fail := func(shard string, stage Stage, err error, cancelShard context.CancelCauseFunc) error {
+		report.Add(PartitionFailure{Shard: shard, Stage: stage, Err: err})
+		if options.onFailure == FailFast {
+			cancel(err)
+			return err
+		}
+		cancelShard(err)
+		return nil
+	}
+
+	var tasks errgroup.Group
+	for _, shard := range shards {
+		l := logger.With(zap.String("shard", shard.Id()))
+		worker := NewShardWorker[T](shard.Id(), options.readBufferSize, l).
+			WithCheckpoints(options.checkpoints).
+			WithDeadLetters(options.deadLetters).
+			WithRun(options.runId, replayOf).
+			WithTimeouts(options.readTimeout, options.processTimeout)
+		worker.results = results
+		for _, listener := range options.listeners {
+			worker.WithListener(listener)
+		}
+		if options.budget != nil {
+			worker.WithGate(options.budget.Gate(shard.Id()))
+		}
+		if options.onFailure == ContinueOnFailure {
+			worker.WithFailureReport(&report)
+		}
+		shardCtx, cancelShard := context.WithCancelCause(ctx)
+		started := time.Now()
+		options.listeners.emit(Event{Type: EventShardStarted, Run: options.runId, Shard: shard.Id(), Time: started})
+		var shardTasks errgroup.Group
+		shardTasks.Go(func() error {
+			defer cancelShard(nil)
+			err := worker.Consume(shardCtx, shard, options.readParallelism, options.readBatchSize, func(metrics WorkerMetrics) {
+				readProgressUpdater.Updates <- map[string]WorkerMetrics{shard.Id(): metrics}
+			}, 0)
+			if err != nil {
+				l.Error("Error consuming shard", zap.Error(err))
+				return fail(shard.Id(), StageRead, err, cancelShard)
+			}
+			return nil
+		})
+		shardTasks.Go(func() error {
+			err := worker.Produce(writeCtx, p.processor, p.sinkFactory, options.writeParallelism, func(metrics WorkerMetrics) {
+				writeProgressUpdater.Updates <- map[string]WorkerMetrics{shard.Id(): metrics}
+				if options.budget == nil {
+					return
+				}
+				if breach := options.budget.Observe(shard.Id(), metrics); breach != nil {
+					if options.budget.Action() == ErrorBudgetPause {
+						l.Warn("Error budget exceeded, pausing reads until resumed", zap.Error(breach))
+					} else {
+						l.Error("Error budget exceeded, cancelling run", zap.Error(breach))
+						cancel(breach)
+					}
+				}
+			})
+			if err != nil {
+				l.Error("Error producing shard", zap.Error(err))
+				return fail(shard.Id(), StageWrite, err, cancelShard)
+			}
+			return nil
+		})
+		tasks.Go(func() error {
+			err := shardTasks.Wait()
+			results.shardFinished(shard.Id(), time.Since(started))
+			options.listeners.emit(Event{
+				Type:     EventShardDone,
+				Run:      options.runId,
+				Shard:    shard.Id(),
+				Duration: time.Since(started),
+				Err:      report.shardErr(shard.Id()),
+			})
+			return err
+		})
+	}
+	var progressUpdatesTasks errgroup.Group
+	readProgressUpdater.Run(&progressUpdatesTasks, 5, func(diff []ShardMetrics) {
+		logger.Info("Read progress", zap.Any("progress", diff))
+		for _, hook := range options.progressHooks {
+			hook(StageRead, diff)
+		}
+	})
+	writeProgressUpdater.Run(&progressUpdatesTasks, 5, func(diff []ShardMetrics) {
+		logger.Info("Write progress changes", zap.Any("progress", diff))
+		for _, hook := range options.progressHooks {
+			hook(StageWrite, diff)
+		}
+	})
+
+	err = tasks.Wait()
+	stopWriting()
+	// a run is interrupted by its caller; one cancelled by a failure or the
+	// error budget has failed, but neither read everything
+	interrupted := parent.Err() != nil
+	stopped := ctx.Err() != nil
+	if err == nil && stopped {
+		err = context.Cause(ctx)
+	}
+	if err == nil {
+		err = report.Err()
+	}
+	for _, failure := range report.Failures() {
+		logger.Error("Failure report", zap.String("shard", failure.Shard), zap.String("partition", failure.Partition), zap.String("stage", string(failure.Stage)), zap.Error(failure.Err))
+	}
+	logger.Info("All shards processed", zap.Error(err), zap.Bool("interrupted", interrupted))
+	readProgressUpdater.Close()
+	writeProgressUpdater.Close()
+	_ = progressUpdatesTasks.Wait()
+	logger.Info(" Reads Stats", zap.Any("stats", readProgressUpdater.Stats()))
+	logger.Info(" Write Stats", zap.Any("stats", writeProgressUpdater.Stats()))
+	if recorder, ok := options.checkpoints.(RunCheckpointer); ok {
+		runCheckpoint := RunCheckpoint{
+			Run:         options.runId,
+			Source:      p.source.Id(),
+			Interrupted: interrupted,
+			Reads:       readProgressUpdater.Stats(),
+			Writes:      writeProgressUpdater.Stats(),
+			FinishedAt:  time.Now(),
+		}
+		if err != nil {
+			runCheckpoint.Error = err.Error()
+		}
+		if commitErr := recorder.CommitRun(runCheckpoint); commitErr != nil {
+			logger.Error("Error committing run checkpoint", zap.Error(commitErr))
+		}
+	}
+	if committer, ok := p.source.(SuccessCommitter); ok && !stopped {
+		if commitErr := committer.CommitSuccess(report.Failures()); commitErr != nil {
+			logger.Error("Error committing source state", zap.Error(commitErr))
+		}
+	}
+	result := results.finish(interrupted, report.Failures())
+	options.listeners.emit(Event{
+		Type:     EventRunFinished,
+		Run:      options.runId,
+		Duration: result.Duration,
+		Err:      err,
+		Result:   result,
+	})
+	return result, err
+}
@@ -0,0 +1,208 @@
+package etl
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// endlessPartition reads batches of 2 records with their number as offset.
+type endlessPartition struct {
+	reads atomic.Int64
+}
+
+func (e *endlessPartition) Id() string {
+	return "endless.table"
+}
+
+func (e *endlessPartition) Done() bool {
+	return false
+}
+
+func (e *endlessPartition) NextBatch(resource NoResource, batchSize int) ([]*int, interface{}, error) {
+	n := int(e.reads.Add(1))
+	first, second := 2*n, 2*n+1
+	return []*int{&first, &second}, n, nil
+}
+
+func (e *endlessPartition) Close() error {
+	return nil
+}
+
+// gatedProcessor holds every batch until released, or until its context is done.
+type gatedProcessor struct {
+	passThrough[int]
+	started chan struct{}
+	once    sync.Once
+	release chan struct{}
+}
+
+func newGatedProcessor() *gatedProcessor {
+	return &gatedProcessor{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (g *gatedProcessor) ProcessBatchContext(ctx context.Context, records []*int) ([]*ProcessedRecord, error) {
+	g.once.Do(func() { close(g.started) })
+	select {
+	case <-g.release:
+		return g.ProcessBatch(records)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	t.Run("TestRunResult", func(t *testing.T) {
+		directory := t.TempDir()
+		pipeline := NewPipeline[int](newFailingSource(t), passThrough[int]{}, NewFSSinkFactory(directory, ENCODER_JSON),
+			WithReadParallelism(2),
+			WithWriteParallelism(2),
+			WithReadBatchSize(4),
+			WithFailurePolicy(ContinueOnFailure),
+			WithRunId("run-1"),
+		)
+		result, err := pipeline.Run(context.Background())
+		assert.Error(t, err)
+		assert.NotNil(t, result)
+
+		assert.Equal(t, "run-1", result.Run)
+		assert.False(t, result.Interrupted)
+		assert.Equal(t, 25, result.Read.Processed)
+		assert.Equal(t, 25, result.Written.Successes)
+
+		healthy := result.Shards["healthy"]
+		assert.Equal(t, 20, healthy.Written.Processed)
+		assert.Equal(t, 20, healthy.Partitions["healthy.table"].Read.Processed)
+		broken := result.Shards["broken"]
+		assert.Equal(t, 5, broken.Partitions["broken.other"].Written.Successes)
+		assert.NotContains(t, broken.Partitions, "broken.table")
+
+		assert.Len(t, result.Failures, 1)
+		assert.Equal(t, "broken.table", result.Failures[0].Partition)
+		assert.Equal(t, []string{
+			filepath.Join(directory, "broken_producer_0.json.gz"),
+			filepath.Join(directory, "broken_producer_1.json.gz"),
+			filepath.Join(directory, "healthy_producer_0.json.gz"),
+			filepath.Join(directory, "healthy_producer_1.json.gz"),
+		}, result.Outputs)
+	})
+
+	t.Run("TestFailFastIsNotAnInterruption", func(t *testing.T) {
+		result, err := NewPipeline[int](newFailingSource(t), passThrough[int]{}, func(string) (ElementWriter, error) { return newMemoryWriter(), nil },
+			WithReadBatchSize(4),
+		).Run(context.Background())
+		assert.EqualError(t, err, "table is gone")
+		assert.False(t, result.Interrupted)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, err = NewPipeline[int](newFailingSource(t), passThrough[int]{}, func(string) (ElementWriter, error) { return newMemoryWriter(), nil }).Run(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, result.Interrupted)
+	})
+
+	t.Run("TestDrainsBufferedBatchesOnCancel", func(t *testing.T) {
+		partition := &endlessPartition{}
+		shard, err := NewFilesShard[int]("shard", []ElementPartition[int, NoResource]{partition})
+		assert.NoError(t, err)
+		store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.jsonl"))
+		assert.NoError(t, err)
+		defer store.Close()
+		processor := newGatedProcessor()
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-processor.started
+			// one batch being processed and 3 buffered
+			for partition.reads.Load() < 4 {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+			close(processor.release)
+		}()
+
+		result, err := NewPipeline[int](&staticSource{shards: []ElementShard[int]{shard}}, processor, func(string) (ElementWriter, error) { return newMemoryWriter(), nil },
+			WithReadParallelism(1),
+			WithWriteParallelism(1),
+			WithReadBufferSize(3),
+			WithCheckpointStore(store),
+			WithShutdownTimeout(time.Minute),
+		).Run(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, result.Interrupted)
+		assert.GreaterOrEqual(t, result.Written.Successes, 8, "buffered batches are written")
+
+		checkpoint, err := store.Load("shard", "endless.table")
+		assert.NoError(t, err)
+		var offset int
+		assert.NoError(t, json.Unmarshal(checkpoint.Offset, &offset))
+		assert.Equal(t, result.Written.Successes/2, offset, "and committed")
+	})
+
+	t.Run("TestShutdownTimeoutAbandonsBufferedBatches", func(t *testing.T) {
+		shard, err := NewFilesShard[int]("shard", []ElementPartition[int, NoResource]{&endlessPartition{}})
+		assert.NoError(t, err)
+		processor := newGatedProcessor()
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-processor.started
+			cancel()
+		}()
+
+		started := time.Now()
+		result, err := NewPipeline[int](&staticSource{shards: []ElementShard[int]{shard}}, processor, func(string) (ElementWriter, error) { return newMemoryWriter(), nil },
+			WithReadBufferSize(3),
+			WithShutdownTimeout(20*time.Millisecond),
+		).Run(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, time.Since(started), 5*time.Second)
+		assert.True(t, result.Interrupted)
+		assert.Zero(t, result.Written.Processed)
+	})
+}
+
+func TestPipelineEvents(t *testing.T) {
+	t.Run("TestLifecycleEvents", func(t *testing.T) {
+		var (
+			mu     sync.Mutex
+			events = make(map[EventType][]Event)
+		)
+		listener := EventListenerFunc(func(event Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events[event.Type] = append(events[event.Type], event)
+		})
+		_, err := NewPipeline[int](newFailingSource(t), passThrough[int]{}, func(string) (ElementWriter, error) { return newMemoryWriter(), nil },
+			WithReadBatchSize(4),
+			WithFailurePolicy(ContinueOnFailure),
+			WithEventListener(listener),
+		).Run(context.Background())
+		assert.Error(t, err)
+
+		assert.Len(t, events[EventShardStarted], 2)
+		assert.Len(t, events[EventShardDone], 2)
+		assert.Len(t, events[EventPartitionOpened], 3)
+		assert.Len(t, events[EventBatchRead], 7)
+		assert.Len(t, events[EventBatchProcessed], 7)
+		assert.Len(t, events[EventBatchWritten], 7)
+		var done []string
+		for _, event := range events[EventPartitionDone] {
+			done = append(done, event.Partition)
+		}
+		assert.ElementsMatch(t, []string{"healthy.table", "broken.other"}, done)
+		for _, event := range events[EventShardDone] {
+			if event.Shard == "broken" {
+				assert.EqualError(t, event.Err, "table is gone")
+			} else {
+				assert.NoError(t, event.Err)
+			}
+		}
+		assert.Len(t, events[EventRunFinished], 1)
+		assert.Equal(t, 25, events[EventRunFinished][0].Result.Written.Processed)
+	})
+}
@@ -17,7 +17,19 @@ type WorkerMetrics struct {
 	Processed int
 	Successes int
 	Errors    int
+	// Throttled is the time reads were paused by the shard to spare its server.
+	Throttled time.Duration `json:",omitempty"`
 }
+
+func (m WorkerMetrics) Add(other WorkerMetrics) WorkerMetrics {
+	return WorkerMetrics{
+		Processed: m.Processed + other.Processed,
+		Successes: m.Successes + other.Successes,
+		Errors:    m.Errors + other.Errors,
+		Throttled: m.Throttled + other.Throttled,
+	}
+}
+
 type ShardMetrics struct {
 	Shard   string
 	Metrics WorkerMetrics
@@ -56,15 +68,7 @@ func (p *ProgressUpdater) Close() {
 func (p *ProgressUpdater) Run(taskGroup *errgroup.Group, topN int, notify func([]ShardMetrics)) {
 
 	isZero := func(m WorkerMetrics) bool {
-		return m.Processed == 0 && m.Successes == 0 && m.Errors == 0
-	}
-
-	addMetric := func(lhs WorkerMetrics, rhs WorkerMetrics) WorkerMetrics {
-		return WorkerMetrics{
-			Processed: lhs.Processed + rhs.Processed,
-			Successes: lhs.Successes + rhs.Successes,
-			Errors:    lhs.Errors + rhs.Errors,
-		}
+		return m.Processed == 0 && m.Successes == 0 && m.Errors == 0 && m.Throttled == 0
 	}
 
 	diffMetric := func(lhs WorkerMetrics, rhs WorkerMetrics) WorkerMetrics {
@@ -72,6 +76,7 @@ func (p *ProgressUpdater) Run(taskGroup *errgroup.Group, topN int, notify func([
 			Processed: lhs.Processed - rhs.Processed,
 			Successes: lhs.Successes - rhs.Successes,
 			Errors:    lhs.Errors - rhs.Errors,
+			Throttled: lhs.Throttled - rhs.Throttled,
 		}
 	}
 
@@ -82,7 +87,7 @@ func (p *ProgressUpdater) Run(taskGroup *errgroup.Group, topN int, notify func([
 				progress[k] = v
 				continue
 			}
-			progress[k] = addMetric(existing, v)
+			progress[k] = existing.Add(v)
 		}
 		return progress
 	}
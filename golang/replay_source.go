@@ -0,0 +1,119 @@
+package etl
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+type replayLetter struct {
+	Id        any             `json:"id"`
+	Shard     string          `json:"shard"`
+	Partition string          `json:"partition"`
+	Record    json.RawMessage `json:"record"`
+	Run       string          `json:"run"`
+}
+
+// ReplaySource turns the dead letters of a previous run back into a source,
+// keeping the original shard and partition of every record.
+type ReplaySource[T any] struct {
+	id       string
+	replayOf string
+	shards   []ElementShard[T]
+}
+
+// NewReplaySource reads the dead letters written in directory. It fails when
+// a dead letter carries no input record, as replaying the others would lose
+// it silently.
+func NewReplaySource[T any](directory string, decoder func(data []byte) (*T, error)) (*ReplaySource[T], error) {
+	files, err := filepath.Glob(filepath.Join(directory, "*"+deadLetterSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		records  = make(map[string]map[string][]*T)
+		replayOf string
+		missing  int
+	)
+	for _, file := range files {
+		reader, err := NewFileElementReaderAutoCompressed[replayLetter](file, JSON_DECODER[replayLetter])
+		if err != nil {
+			return nil, err
+		}
+		for !reader.Done() {
+			letters, _, err := reader.NextBatch(NoResource{}, 1000)
+			if err != nil {
+				_ = reader.Close()
+				return nil, err
+			}
+			for _, letter := range letters {
+				if len(letter.Record) == 0 || string(letter.Record) == "null" {
+					missing++
+					continue
+				}
+				record, err := decoder(letter.Record)
+				if err != nil {
+					_ = reader.Close()
+					return nil, err
+				}
+				if replayOf == "" {
+					replayOf = letter.Run
+				}
+				if _, ok := records[letter.Shard]; !ok {
+					records[letter.Shard] = make(map[string][]*T)
+				}
+				records[letter.Shard][letter.Partition] = append(records[letter.Shard][letter.Partition], record)
+			}
+		}
+	}
+	if missing > 0 {
+		return nil, fmt.Errorf("%d dead letters in %s carry no input record and cannot be replayed", missing, directory)
+	}
+	if replayOf == "" {
+		replayOf = filepath.Base(filepath.Dir(filepath.Clean(directory)))
+	}
+
+	var shards []ElementShard[T]
+	for _, shard := range sortedKeys(records) {
+		var partitions []ElementPartition[T, NoResource]
+		for _, partition := range sortedKeys(records[shard]) {
+			partitions = append(partitions, &SlicePartition[T]{
+				id:   partition,
+				data: records[shard][partition],
+			})
+		}
+		replayShard, err := NewFilesShard[T](shard, partitions)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, replayShard)
+	}
+	return &ReplaySource[T]{
+		id:       "replay__" + replayOf,
+		replayOf: replayOf,
+		shards:   shards,
+	}, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (s *ReplaySource[T]) Id() string {
+	return s.id
+}
+
+func (s *ReplaySource[T]) Shards() ([]ElementShard[T], error) {
+	return s.shards, nil
+}
+
+// ReplayOf is the run whose dead letters are being replayed.
+func (s *ReplaySource[T]) ReplayOf() string {
+	return s.replayOf
+}
@@ -0,0 +1,90 @@
+package etl
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type replayRecord struct {
+	Name string `json:"name"`
+}
+
+func TestReplaySource(t *testing.T) {
+	t.Run("TestReplaysDeadLettersGroupedByPartition", func(t *testing.T) {
+		directory := filepath.Join(t.TempDir(), "run-1", "dead_letters")
+		factory := NewFSDeadLetterFactory(directory)
+		batches := []*PartitionRecordBatch[replayRecord]{
+			{Shard: "shard-a", Partition: "db1.table", Offset: 10},
+			{Shard: "shard-a", Partition: "db2.table", Offset: 20},
+			{Shard: "shard-b", Partition: "db1.table", Offset: 30},
+		}
+		for i, producer := range []string{"producer_0", "producer_1"} {
+			writer, err := factory(producer)
+			assert.NoError(t, err)
+			for _, batch := range batches[i:] {
+				deadLetter := NewDeadLetter(batch, batch.Offset, &replayRecord{Name: batch.Partition}, errors.New("render failed"))
+				deadLetter.Run = "run-1"
+				assert.NoError(t, writer.Write(deadLetter))
+			}
+			assert.NoError(t, writer.Close())
+		}
+
+		source, err := NewReplaySource[replayRecord](directory, JSON_DECODER[replayRecord])
+		assert.NoError(t, err)
+		assert.Equal(t, "run-1", source.ReplayOf())
+
+		shards, err := source.Shards()
+		assert.NoError(t, err)
+		assert.Len(t, shards, 2)
+		counts := make(map[string]int)
+		for _, shard := range shards {
+			partitions, err := shard.(ResourceShard[replayRecord, NoResource]).Partitions()
+			assert.NoError(t, err)
+			for _, partition := range partitions {
+				records, _, err := partition.NextBatch(NoResource{}, 100)
+				assert.NoError(t, err)
+				for _, record := range records {
+					assert.Equal(t, partition.Id(), record.Name)
+				}
+				counts[shard.Id()+"/"+partition.Id()] = len(records)
+			}
+		}
+		assert.Equal(t, map[string]int{
+			"shard-a/db1.table": 1,
+			"shard-a/db2.table": 2,
+			"shard-b/db1.table": 2,
+		}, counts)
+	})
+
+	t.Run("TestFailsOnDeadLettersWithoutInput", func(t *testing.T) {
+		directory := t.TempDir()
+		writer, err := NewFSDeadLetterFactory(directory)("producer_0")
+		assert.NoError(t, err)
+		batch := &PartitionRecordBatch[replayRecord]{Shard: "shard-a", Partition: "db1.table"}
+		assert.NoError(t, writer.Write(NewDeadLetter(batch, "input", &replayRecord{Name: "a"}, errors.New("render failed"))))
+		assert.NoError(t, writer.Write(NewDeadLetter(batch, "no-input", nil, errors.New("lost"))))
+		assert.NoError(t, writer.Close())
+
+		_, err = NewReplaySource[replayRecord](directory, JSON_DECODER[replayRecord])
+		assert.ErrorContains(t, err, "1 dead letters")
+	})
+
+	t.Run("TestReplayedOutputNamesTheRun", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.json.gz")
+		writer, err := NewFileElementWriter(path, ENCODER_JSON)
+		assert.NoError(t, err)
+		writer.(ReplayWriter).SetReplayOf("run-1")
+		assert.NoError(t, writer.Append("id", &replayRecord{Name: "a"}))
+		assert.NoError(t, writer.Close())
+
+		reader, err := NewFileElementReaderAutoCompressed[map[string]any](path, JSON_DECODER[map[string]any])
+		assert.NoError(t, err)
+		envelopes, _, err := reader.NextBatch(NoResource{}, 10)
+		assert.NoError(t, err)
+		assert.Len(t, envelopes, 1)
+		assert.Equal(t, "run-1", (*envelopes[0])["replay_of"])
+	})
+}
@@ -0,0 +1,63 @@
+package etl
+
+import (
+	"sync"
+)
+
+// Lease is a resource held by one chunk of a shard, such as a lease on a
+// SharedResource. Closing it releases the resource instead of closing it.
+type Lease[R any] struct {
+	Resource R
+	release  func() error
+	once     sync.Once
+}
+
+func (l *Lease[R]) Close() error {
+	var err error
+	l.once.Do(func() {
+		err = l.release()
+	})
+	return err
+}
+
+// SharedResource opens a single R for all the chunks of a shard, such as a
+// connection pool, and closes it once the last lease on it is released. The
+// next lease opens a new one.
+type SharedResource[R Closeable] struct {
+	open func() (R, error)
+
+	mu       sync.Mutex
+	resource R
+	leases   int
+}
+
+func NewSharedResource[R Closeable](open func() (R, error)) *SharedResource[R] {
+	return &SharedResource[R]{open: open}
+}
+
+func (s *SharedResource[R]) Lease() (*Lease[R], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases == 0 {
+		resource, err := s.open()
+		if err != nil {
+			return nil, err
+		}
+		s.resource = resource
+	}
+	s.leases++
+	return &Lease[R]{Resource: s.resource, release: s.release}, nil
+}
+
+func (s *SharedResource[R]) release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leases--
+	if s.leases > 0 {
+		return nil
+	}
+	resource := s.resource
+	var zero R
+	s.resource = zero
+	return resource.Close()
+}
@@ -0,0 +1,97 @@
+package etl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type countingResource struct {
+	closed int
+}
+
+func (r *countingResource) Close() error {
+	r.closed++
+	return nil
+}
+
+// resourcePartition records the resource it read its single batch from.
+type resourcePartition struct {
+	id   string
+	read *countingResource
+}
+
+func (p *resourcePartition) Id() string { return p.id }
+func (p *resourcePartition) Done() bool { return p.read != nil }
+func (p *resourcePartition) Close() error {
+	return nil
+}
+func (p *resourcePartition) NextBatch(resource *countingResource, batchSize int) ([]*int, interface{}, error) {
+	p.read = resource
+	return []*int{new(int)}, 1, nil
+}
+
+type countingShard struct {
+	shared     *SharedResource[*countingResource]
+	partitions []ElementPartition[int, *countingResource]
+}
+
+func (s *countingShard) Id() string { return "shard" }
+func (s *countingShard) NewResource() (*Lease[*countingResource], error) {
+	return s.shared.Lease()
+}
+func (s *countingShard) Partitions() ([]ElementPartition[int, *countingResource], error) {
+	return s.partitions, nil
+}
+func (s *countingShard) ConsumeWith(ctx context.Context, worker *ShardWorker[int], readParallelism int, readBatchSize int, notifyUpdateTo func(WorkerMetrics), maxBatchesPerChunk int) error {
+	return ConsumeShard[int, *countingResource](ctx, worker, s, readParallelism, readBatchSize, notifyUpdateTo, maxBatchesPerChunk)
+}
+
+func TestSharedResource(t *testing.T) {
+	t.Run("TestClosedByLastLease", func(t *testing.T) {
+		var opened []*countingResource
+		shared := NewSharedResource(func() (*countingResource, error) {
+			opened = append(opened, &countingResource{})
+			return opened[len(opened)-1], nil
+		})
+
+		first, err := shared.Lease()
+		assert.NoError(t, err)
+		second, err := shared.Lease()
+		assert.NoError(t, err)
+		assert.Len(t, opened, 1, "chunks share one resource")
+		assert.Same(t, first.Resource, second.Resource)
+
+		assert.NoError(t, first.Close())
+		assert.NoError(t, first.Close(), "closing a lease twice releases it once")
+		assert.Equal(t, 0, opened[0].closed)
+		assert.NoError(t, second.Close())
+		assert.Equal(t, 1, opened[0].closed)
+
+		third, err := shared.Lease()
+		assert.NoError(t, err)
+		assert.Len(t, opened, 2, "a lease after the last release opens a new resource")
+		assert.NoError(t, third.Close())
+	})
+
+	t.Run("TestPartitionsReadTheResourceOfTheirShard", func(t *testing.T) {
+		var opened []*countingResource
+		shard := &countingShard{shared: NewSharedResource(func() (*countingResource, error) {
+			opened = append(opened, &countingResource{})
+			return opened[len(opened)-1], nil
+		})}
+		for _, id := range []string{"a", "b", "c"} {
+			shard.partitions = append(shard.partitions, &resourcePartition{id: id})
+		}
+		worker := NewShardWorker[int]("shard", 10, zap.NewNop())
+		assert.NoError(t, worker.Consume(context.Background(), shard, 3, 10, func(WorkerMetrics) {}, 0))
+		for _, partition := range shard.partitions {
+			assert.Contains(t, opened, partition.(*resourcePartition).read)
+		}
+		for _, resource := range opened {
+			assert.Equal(t, 1, resource.closed)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+}
+
+// IsTransientError reports whether err is worth retrying: timeouts, dropped
+// connections and 429/5xx responses.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return false
+}
+
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomises each backoff by up to this fraction of its value.
+	Jitter    float64
+	Retryable func(error) bool
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		Retryable:      IsTransientError,
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable == nil {
+		return IsTransientError(err)
+	}
+	return p.Retryable(err)
+}
+
+// Backoff returns the delay before the given retry, attempt 1 being the first.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(max(backoff, 0))
+}
+
+type RetryingProcessor[T any] struct {
+	processor ContextElementProcessor[T]
+	policy    RetryPolicy
+	recordId  func(*T) any
+	sleep     func(context.Context, time.Duration) error
+}
+
+// NewRetryingProcessor retries failed batches according to policy. A batch
+// failing with an error that is not retryable is bisected so only the
+// records that keep failing are reported as errors, while one still failing
+// with a retryable error once attempts are exhausted fails as a whole, so an
+// unavailable processor is not called once per record. recordId names the
+// failures.
+func NewRetryingProcessor[T any](processor ElementProcessor[T], policy RetryPolicy, recordId func(*T) any) ElementProcessor[T] {
+	return &RetryingProcessor[T]{
+		processor: ProcessorWithContext(processor),
+		policy:    policy,
+		recordId:  recordId,
+		sleep:     sleepContext,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func DBRecordId[T any](record *DBRecord[T]) any {
+	return record.Id
+}
+
+func (r *RetryingProcessor[T]) Process(record *T) *ProcessedRecord {
+	results, err := r.ProcessBatch([]*T{record})
+	if err != nil || len(results) == 0 {
+		return r.failed(record, err)
+	}
+	return results[0]
+}
+
+func (r *RetryingProcessor[T]) ProcessBatch(records []*T) ([]*ProcessedRecord, error) {
+	return r.ProcessBatchContext(context.Background(), records)
+}
+
+func (r *RetryingProcessor[T]) ProcessBatchContext(ctx context.Context, records []*T) ([]*ProcessedRecord, error) {
+	if len(records) == 0 {
+		return []*ProcessedRecord{}, nil
+	}
+	results, err := r.processWithRetry(ctx, records)
+	if err == nil {
+		return results, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	if len(records) == 1 || r.policy.retryable(err) {
+		failed := make([]*ProcessedRecord, 0, len(records))
+		for _, record := range records {
+			failed = append(failed, r.failed(record, err))
+		}
+		return failed, nil
+	}
+	mid := len(records) / 2
+	left, err := r.ProcessBatchContext(ctx, records[:mid])
+	if err != nil {
+		return nil, err
+	}
+	right, err := r.ProcessBatchContext(ctx, records[mid:])
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+func (r *RetryingProcessor[T]) processWithRetry(ctx context.Context, records []*T) ([]*ProcessedRecord, error) {
+	var (
+		results []*ProcessedRecord
+		err     error
+	)
+	for attempt := 1; ; attempt++ {
+		results, err = r.processor.ProcessBatchContext(ctx, records)
+		if err == nil && len(results) != len(records) {
+			return nil, fmt.Errorf("processor returned %d results for %d records", len(results), len(records))
+		}
+		if err == nil {
+			return results, nil
+		}
+		if attempt >= r.policy.MaxAttempts || !r.policy.retryable(err) {
+			return nil, err
+		}
+		if sleepErr := r.sleep(ctx, r.policy.Backoff(attempt)); sleepErr != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *RetryingProcessor[T]) failed(record *T, err error) *ProcessedRecord {
+	if err == nil {
+		err = errors.New("processor returned no result")
+	}
+	var id any
+	if r.recordId != nil {
+		id = r.recordId(record)
+	}
+	return &ProcessedRecord{
+		Id:    id,
+		Err:   err,
+		Input: record,
+	}
+}
@@ -0,0 +1,113 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type flakyProcessor struct {
+	poisoned  map[int]bool
+	transient int
+	dropped   map[int]bool
+	calls     int
+}
+
+func (f *flakyProcessor) Process(record *int) *ProcessedRecord {
+	return &ProcessedRecord{Id: *record, Record: *record}
+}
+
+func (f *flakyProcessor) ProcessBatch(records []*int) ([]*ProcessedRecord, error) {
+	f.calls++
+	if f.transient > 0 {
+		f.transient--
+		return nil, &HTTPStatusError{StatusCode: 503}
+	}
+	var results []*ProcessedRecord
+	for _, record := range records {
+		if f.poisoned[*record] {
+			return nil, errors.New("poisoned record")
+		}
+		if f.dropped[*record] {
+			continue
+		}
+		results = append(results, f.Process(record))
+	}
+	return results, nil
+}
+
+func intRecords(n int) []*int {
+	var records []*int
+	for i := range n {
+		records = append(records, &i)
+	}
+	return records
+}
+
+func TestRetryingProcessor(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	noSleep := func(context.Context, time.Duration) error { return nil }
+
+	t.Run("TestRetriesTransientErrors", func(t *testing.T) {
+		inner := &flakyProcessor{transient: 2}
+		processor := NewRetryingProcessor[int](inner, policy, func(r *int) any { return *r }).(*RetryingProcessor[int])
+		processor.sleep = noSleep
+		results, err := processor.ProcessBatch(intRecords(4))
+		assert.NoError(t, err)
+		assert.Len(t, results, 4)
+		assert.Equal(t, 3, inner.calls)
+	})
+
+	t.Run("TestBisectsToIsolatePoisonedRecords", func(t *testing.T) {
+		inner := &flakyProcessor{poisoned: map[int]bool{2: true, 5: true}}
+		processor := NewRetryingProcessor[int](inner, policy, func(r *int) any { return *r }).(*RetryingProcessor[int])
+		processor.sleep = noSleep
+		results, err := processor.ProcessBatch(intRecords(8))
+		assert.NoError(t, err)
+		assert.Len(t, results, 8)
+		var failed []any
+		for _, result := range results {
+			if result.Err != nil {
+				failed = append(failed, result.Id)
+			}
+		}
+		slices.SortFunc(failed, func(a, b any) int { return a.(int) - b.(int) })
+		assert.Equal(t, []any{2, 5}, failed)
+	})
+
+	t.Run("TestExhaustedTransientErrorsFailTheBatch", func(t *testing.T) {
+		inner := &flakyProcessor{transient: 100}
+		processor := NewRetryingProcessor[int](inner, policy, func(r *int) any { return *r }).(*RetryingProcessor[int])
+		processor.sleep = noSleep
+		results, err := processor.ProcessBatch(intRecords(8))
+		assert.NoError(t, err)
+		assert.Len(t, results, 8)
+		for _, result := range results {
+			assert.Error(t, result.Err)
+		}
+		assert.Equal(t, policy.MaxAttempts, inner.calls, "the batch is not bisected")
+	})
+
+	t.Run("TestMissingResultsAreFailures", func(t *testing.T) {
+		inner := &flakyProcessor{dropped: map[int]bool{3: true}}
+		processor := NewRetryingProcessor[int](inner, policy, func(r *int) any { return *r }).(*RetryingProcessor[int])
+		processor.sleep = noSleep
+		results, err := processor.ProcessBatch(intRecords(4))
+		assert.NoError(t, err)
+		assert.Len(t, results, 4)
+		for _, result := range results {
+			assert.Equal(t, result.Id == 3, result.Err != nil)
+		}
+	})
+
+	t.Run("TestBackoffIsCapped", func(t *testing.T) {
+		p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+		assert.Equal(t, time.Second, p.Backoff(1))
+		assert.Equal(t, 4*time.Second, p.Backoff(3))
+		assert.Equal(t, 5*time.Second, p.Backoff(10))
+	})
+}
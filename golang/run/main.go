@@ -18,42 +18,126 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 )
 
 func RunETL(ctx context.Context, logger *zap.Logger) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return err
 	}
 	now := time.Now()
-	runId := strings.ReplaceAll(strings.ReplaceAll(now.Format(time.DateTime), " ", "_"), ":", "-")
+	// a run is resumed, from its own checkpoints and into its own output, by
+	// naming it in ETL_RESUME_RUN
+	runId := os.Getenv("ETL_RESUME_RUN")
+	if runId == "" {
+		runId = strings.ReplaceAll(strings.ReplaceAll(now.Format(time.DateTime), " ", "_"), ":", "-")
+	}
 	outputDir := homeDir + "/data/deliveries_" + runId
 	readParallelismPerShard := 10
 	writeParallelismPerShard := 10
 	recordBatchSize := 50
-	sinkFactory := etl.NewFSSinkFactory(outputDir, etl.ENCODER_JSON)
 	var hosts = CIO_HOSTS // []string{"localhost"}
-	source, err := etl.NewMySQLSource[DeliveryDBRecord](hosts, "root", "production_env*", "delivs_2024_11")
+	table := "delivs_2024_11"
+	checkpointPath := outputDir + "/checkpoints.jsonl"
+
+	replayFrom := os.Getenv("ETL_REPLAY_FROM")
+	if replayFrom != "" {
+		// the replay of a run resumes from its own checkpoints whatever its
+		// run id, and is not replayed twice
+		outputDir = replayFrom + "/replay"
+		checkpointPath = outputDir + "/checkpoints.jsonl"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	checkpoints, err := etl.NewFileCheckpointStore(checkpointPath)
 	if err != nil {
 		return err
 	}
+	defer checkpoints.Close()
+
+	var source etl.ElementSource[etl.DBRecord[DeliveryDBRecord]]
+	if replayFrom != "" {
+		replay, err := etl.NewReplaySource[etl.DBRecord[DeliveryDBRecord]](replayFrom+"/dead_letters", etl.JSON_DECODER[etl.DBRecord[DeliveryDBRecord]])
+		if err != nil {
+			return err
+		}
+		logger.Info("Replaying dead letters", zap.String("replayOf", replay.ReplayOf()))
+		source = replay
+	} else {
+		profile := etl.ConnectionProfile{DialTimeout: 10 * time.Second, ReadTimeout: 10 * time.Minute}
+		if _, ok := os.LookupEnv("ETL_MYSQL_PASSWORD"); ok {
+			profile.Password = etl.EnvSecret("ETL_MYSQL_PASSWORD")
+		}
+		logger.Info("Connecting", zap.Object("profile", profile))
+		var preflight etl.PreflightReport
+		source, err = etl.NewMySQLSource[DeliveryDBRecord](hosts, "root", `production_env(?P<env_id>\d+)`, table,
+			etl.WithKeyRangeSplit(etl.KeyRangeSplit{Partitions: readParallelismPerShard, MinRows: 10_000_000, Checkpoints: checkpoints}),
+			etl.WithConnectionProfile(profile),
+			etl.WithPool(etl.MySQLPool{MaxOpen: readParallelismPerShard, MaxIdle: readParallelismPerShard, MaxIdleTime: time.Minute}),
+			etl.WithThrottle(etl.Throttle{MaxThreadsRunning: 64, MaxReplicaLag: 30 * time.Second}),
+			etl.WithConnectionBudget(etl.NewConnectionBudget(200, readParallelismPerShard+1)),
+			etl.WithPreflightReport(&preflight),
+			etl.WithPartialShards())
+		for _, host := range preflight.Hosts {
+			logger.Info("Preflight",
+				zap.String("host", host.Host),
+				zap.Error(host.Err),
+				zap.Int("partitions", len(host.Partitions)),
+				zap.Any("skipped", host.Skipped),
+				zap.Duration("duration", host.Duration),
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	logger.Info("Run", zap.String("runId", runId), zap.String("checkpoints", checkpointPath))
+	sinkFactory := etl.NewFSSinkFactory(outputDir, etl.ENCODER_JSON)
+
+	budget := etl.NewErrorBudget(etl.ErrorBudgetConfig{
+		Global:   etl.ErrorBudgetLimits{MaxErrorRate: 0.5, MinProcessed: 1000},
+		PerShard: etl.ErrorBudgetLimits{MaxErrorRate: 0.8, MinProcessed: 500},
+		Window:   time.Minute,
+		Action:   etl.ErrorBudgetPause,
+	})
+	go resumeOnSignal(ctx, budget, logger)
 
 	logger.Info("Starting ETL", zap.String("outputDir", outputDir))
 
-	err = etl.ExecuteAll(
-		ctx,
+	pipeline := etl.NewPipeline(
 		source,
-		readParallelismPerShard,
-		writeParallelismPerShard,
-		100,
-		recordBatchSize,
-		NewDeliverRenderRequestProcessor(),
+		etl.NewRetryingProcessor(NewDeliverRenderRequestProcessor(), etl.DefaultRetryPolicy(), etl.DBRecordId[DeliveryDBRecord]),
 		sinkFactory,
-		logger,
+		etl.WithReadParallelism(readParallelismPerShard),
+		etl.WithWriteParallelism(writeParallelismPerShard),
+		etl.WithReadBufferSize(100),
+		etl.WithReadBatchSize(recordBatchSize),
+		etl.WithLogger(logger),
+		etl.WithCheckpointStore(checkpoints),
+		etl.WithDeadLetters(etl.NewFSDeadLetterFactory(outputDir+"/dead_letters")),
+		etl.WithRunId(runId),
+		etl.WithErrorBudget(budget),
+		etl.WithShutdownTimeout(time.Minute),
+		etl.WithFailurePolicy(etl.ContinueOnFailure),
+		etl.WithCallTimeouts(5*time.Minute, 2*time.Minute),
 	)
+	result, err := pipeline.Run(ctx)
+	if result != nil {
+		logger.Info("Run result",
+			zap.Any("read", result.Read),
+			zap.Any("written", result.Written),
+			zap.Int("failures", len(result.Failures)),
+			zap.Strings("outputs", result.Outputs),
+			zap.Bool("interrupted", result.Interrupted),
+		)
+	}
 	if err != nil {
 		logger.Error("Error while Running All ", zap.Error(err), zap.Duration("duration", time.Since(now)))
 		return err
@@ -61,6 +145,23 @@ func RunETL(ctx context.Context, logger *zap.Logger) error {
 	logger.Info("Finished ETL ", zap.Duration("duration", time.Since(now)))
 	return nil
 }
+
+// resumeOnSignal lets an operator resume a run paused by its error budget with SIGUSR1.
+func resumeOnSignal(ctx context.Context, budget *etl.ErrorBudget, logger *zap.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			logger.Info("Resuming reads", zap.Bool("wasPaused", budget.Paused()))
+			budget.Resume()
+		}
+	}
+}
+
 func NewLogger(runId string) (*zap.Logger, error) {
 	cfg := zap.NewProductionConfig()
 	cfg.OutputPaths = []string{
@@ -96,7 +197,7 @@ func cioDeliveryExtractHttp() etl.ElementProcessor[etl.DBRecord[DeliveryDBRecord
 		path           = "v1/rc/render_deliveries"
 		requestBuilder = func(records []*etl.DBRecord[DeliveryDBRecord]) (*http.Request, error) {
 			v := url.Values{}
-			v.Add("env_id", strings.Split(records[0].DataBase, "production_env")[1])
+			v.Add("env_id", records[0].Labels.String("env_id"))
 			v.Add("include_liquid_response", "false")
 
 			deliveryIds := make([]string, 0, len(records))
@@ -156,11 +257,15 @@ func (d *DeliverRenderRequest) Process(record *etl.DBRecord[DeliveryDBRecord]) *
 }
 
 func (d *DeliverRenderRequest) ProcessBatch(records []*etl.DBRecord[DeliveryDBRecord]) ([]*etl.ProcessedRecord, error) {
-	env, err := strconv.Atoi(strings.Split(records[0].DataBase, "production_env")[1])
+	return d.ProcessBatchContext(context.Background(), records)
+}
+
+func (d *DeliverRenderRequest) ProcessBatchContext(ctx context.Context, records []*etl.DBRecord[DeliveryDBRecord]) ([]*etl.ProcessedRecord, error) {
+	env, err := records[0].Labels.Int("env_id")
 	if err != nil {
 		return nil, err
 	}
-	ctx := ciocontext.NewEnv(context.Background(), env)
+	ctx = ciocontext.NewEnv(ctx, env)
 	var uuids [][]byte
 	for _, record := range records {
 		uuids = append(uuids, record.Record.Id)
@@ -0,0 +1,122 @@
+package etl
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+type PartitionResult struct {
+	Shard     string
+	Partition string
+	Read      WorkerMetrics
+	Written   WorkerMetrics
+	// ReadTime and WriteTime add up the time spent in NextBatch and in
+	// processing and writing the batches of this partition.
+	ReadTime  time.Duration
+	WriteTime time.Duration
+}
+
+type ShardResult struct {
+	Shard      string
+	Read       WorkerMetrics
+	Written    WorkerMetrics
+	Duration   time.Duration
+	Partitions map[string]*PartitionResult
+}
+
+type RunResult struct {
+	Run         string
+	Source      string
+	StartedAt   time.Time
+	Duration    time.Duration
+	Interrupted bool
+	Read        WorkerMetrics
+	Written     WorkerMetrics
+	Shards      map[string]*ShardResult
+	Failures    []PartitionFailure
+	Outputs     []string
+}
+
+type runResultCollector struct {
+	mu     sync.Mutex
+	result *RunResult
+}
+
+func newRunResultCollector(run, source string) *runResultCollector {
+	return &runResultCollector{
+		result: &RunResult{
+			Run:       run,
+			Source:    source,
+			StartedAt: time.Now(),
+			Shards:    make(map[string]*ShardResult),
+		},
+	}
+}
+
+func (c *runResultCollector) shard(shard string) *ShardResult {
+	result, ok := c.result.Shards[shard]
+	if !ok {
+		result = &ShardResult{Shard: shard, Partitions: make(map[string]*PartitionResult)}
+		c.result.Shards[shard] = result
+	}
+	return result
+}
+
+func (c *runResultCollector) partition(shard, partition string) *PartitionResult {
+	shardResult := c.shard(shard)
+	result, ok := shardResult.Partitions[partition]
+	if !ok {
+		result = &PartitionResult{Shard: shard, Partition: partition}
+		shardResult.Partitions[partition] = result
+	}
+	return result
+}
+
+func (c *runResultCollector) read(shard, partition string, metrics WorkerMetrics, elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result.Read = c.result.Read.Add(metrics)
+	shardResult := c.shard(shard)
+	shardResult.Read = shardResult.Read.Add(metrics)
+	partitionResult := c.partition(shard, partition)
+	partitionResult.Read = partitionResult.Read.Add(metrics)
+	partitionResult.ReadTime += elapsed
+}
+
+func (c *runResultCollector) written(shard, partition string, metrics WorkerMetrics, elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result.Written = c.result.Written.Add(metrics)
+	shardResult := c.shard(shard)
+	shardResult.Written = shardResult.Written.Add(metrics)
+	partitionResult := c.partition(shard, partition)
+	partitionResult.Written = partitionResult.Written.Add(metrics)
+	partitionResult.WriteTime += elapsed
+}
+
+func (c *runResultCollector) shardFinished(shard string, elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shard(shard).Duration = elapsed
+}
+
+func (c *runResultCollector) output(writer interface{}) {
+	locator, ok := writer.(Locator)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result.Outputs = append(c.result.Outputs, locator.Location())
+}
+
+func (c *runResultCollector) finish(interrupted bool, failures []PartitionFailure) *RunResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result.Duration = time.Since(c.result.StartedAt)
+	c.result.Interrupted = interrupted
+	c.result.Failures = failures
+	slices.Sort(c.result.Outputs)
+	return c.result
+}
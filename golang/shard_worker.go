@@ -3,6 +3,7 @@ package etl
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
@@ -12,12 +13,39 @@ type ProcessedRecord struct {
 	Id     any
 	Record interface{}
 	Err    error
+	Input  interface{}
+	// Labels default to the labels of the batch's records.
+	Labels Labels
 }
 
 type ShardWorker[T any] struct {
-	Id     string
-	logger *zap.Logger
-	buffer chan PartitionRecordBatch[T]
+	Id          string
+	logger      *zap.Logger
+	buffer      chan PartitionRecordBatch[T]
+	checkpoints *checkpointTracker
+	deadLetters DeadLetterWriterFactory
+	runId       string
+	replayOf    string
+	gate        ConsumeGate
+	failures    *FailureReport
+
+	readTimeout    time.Duration
+	processTimeout time.Duration
+
+	results   *runResultCollector
+	listeners eventListeners
+}
+
+// ConsumeGate is consulted before every read; Wait blocks while reading is paused.
+type ConsumeGate interface {
+	Wait(ctx context.Context) error
+}
+
+// ThrottledShard is implemented by shards pausing their reads to spare the
+// server they read from. Throttle is called before every read and returns
+// how long it paused, reported as throttled time in the read metrics.
+type ThrottledShard interface {
+	Throttle(ctx context.Context) (time.Duration, error)
 }
 
 func NewShardWorker[T any](
@@ -26,10 +54,53 @@ func NewShardWorker[T any](
 	logger *zap.Logger,
 ) *ShardWorker[T] {
 	return &ShardWorker[T]{
-		Id:     id,
-		logger: logger,
-		buffer: make(chan PartitionRecordBatch[T], readBufferSize),
+		Id:          id,
+		logger:      logger,
+		buffer:      make(chan PartitionRecordBatch[T], readBufferSize),
+		checkpoints: newCheckpointTracker(id, nil),
+	}
+}
+
+func (s *ShardWorker[T]) WithListener(listener EventListener) *ShardWorker[T] {
+	if listener != nil {
+		s.listeners = append(s.listeners, listener)
 	}
+	return s
+}
+
+func (s *ShardWorker[T]) WithDeadLetters(factory DeadLetterWriterFactory) *ShardWorker[T] {
+	s.deadLetters = factory
+	return s
+}
+
+func (s *ShardWorker[T]) WithGate(gate ConsumeGate) *ShardWorker[T] {
+	s.gate = gate
+	return s
+}
+
+// WithFailureReport makes Consume record partitions that fail to read in
+// report and carry on with the others instead of returning the error.
+func (s *ShardWorker[T]) WithFailureReport(report *FailureReport) *ShardWorker[T] {
+	s.failures = report
+	return s
+}
+
+// WithTimeouts bounds every NextBatch and ProcessBatch call; zero disables a timeout.
+func (s *ShardWorker[T]) WithTimeouts(read, process time.Duration) *ShardWorker[T] {
+	s.readTimeout = read
+	s.processTimeout = process
+	return s
+}
+
+func (s *ShardWorker[T]) WithRun(runId, replayOf string) *ShardWorker[T] {
+	s.runId = runId
+	s.replayOf = replayOf
+	return s
+}
+
+func (s *ShardWorker[T]) WithCheckpoints(store CheckpointStore) *ShardWorker[T] {
+	s.checkpoints.store = store
+	return s
 }
 
 func (s *ShardWorker[T]) Produce(
@@ -39,6 +110,7 @@ func (s *ShardWorker[T]) Produce(
 	writeParallelism int,
 	notifyUpdateTo func(WorkerMetrics),
 ) error {
+	contextProcessor := ProcessorWithContext(processor)
 	var tasks errgroup.Group
 	for i := range writeParallelism {
 		tasks.Go(func() error {
@@ -55,11 +127,30 @@ func (s *ShardWorker[T]) Produce(
 					logger.Error("Error closing sink", zap.Error(err))
 				}
 			}(sink)
+			if replayWriter, ok := sink.(ReplayWriter); ok && s.replayOf != "" {
+				replayWriter.SetReplayOf(s.replayOf)
+			}
+			s.recordOutput(sink)
+			var deadLetters DeadLetterWriter
+			if s.deadLetters != nil {
+				deadLetters, err = s.deadLetters(producerName)
+				if err != nil {
+					return err
+				}
+				defer func(deadLetters DeadLetterWriter) {
+					err := deadLetters.Close()
+					if err != nil {
+						logger.Error("Error closing dead letters", zap.Error(err))
+					}
+				}(deadLetters)
+				s.recordOutput(deadLetters)
+			}
 			logger.Info("Shard Producer started")
 		Loop:
 			for {
 				select {
 				case <-ctx.Done():
+					logger.Warn("Shard Producer stopped before draining buffer", zap.Int("buffered", len(s.buffer)))
 					return nil
 				case inputBatch, ok := <-s.buffer:
 					if !ok {
@@ -67,28 +158,75 @@ func (s *ShardWorker[T]) Produce(
 						break Loop
 					}
 					metrics := WorkerMetrics{}
-					transformedBatch, err := processor.ProcessBatch(inputBatch.Records)
+					started := time.Now()
+					transformedBatch, err := s.processBatch(ctx, contextProcessor, inputBatch.Records)
+					if err != nil && ctx.Err() != nil {
+						// shut down mid-batch: left uncommitted, so it is read again on resume
+						logger.Warn("Shard Producer stopped while processing a batch", zap.String("partition", inputBatch.Partition))
+						return nil
+					}
+					s.listeners.emit(Event{
+						Type:      EventBatchProcessed,
+						Run:       s.runId,
+						Shard:     s.Id,
+						Partition: inputBatch.Partition,
+						Offset:    inputBatch.Offset,
+						Metrics:   WorkerMetrics{Processed: len(inputBatch.Records)},
+						Duration:  time.Since(started),
+						Err:       err,
+					})
+					written := time.Now()
 					if err != nil {
 						logger.Error("Error processing batch", zap.Error(err))
 						var batchErrors []*ProcessedRecord
 						for i := range len(inputBatch.Records) {
 							batchErrors = append(batchErrors, &ProcessedRecord{
-								Id:  inputBatch.RecordId(i),
-								Err: err,
+								Id:    inputBatch.RecordId(i),
+								Err:   err,
+								Input: inputBatch.Records[i],
 							})
 						}
 						transformedBatch = batchErrors
 					}
+					fillInputs(&inputBatch, transformedBatch)
+					labels := batchLabels(inputBatch.Records)
 					for _, output := range transformedBatch {
 						metrics.Processed++
+						if output.Labels == nil {
+							output.Labels = labels
+						}
 						if output.Err != nil {
 							metrics.Errors++
-							_ = sink.AppendError(output.Id, output.Err)
+							if deadLetters == nil {
+								_ = appendError(sink, output)
+							} else if err := deadLetters.Write(s.deadLetter(&inputBatch, output)); err != nil {
+								logger.Error("Error writing dead letter", zap.Error(err))
+							}
 						} else {
 							metrics.Successes++
-							_ = sink.Append(output.Id, output.Record)
+							_ = appendRecord(sink, output)
 						}
 					}
+					done, err := s.commitBatch(inputBatch, sink, deadLetters)
+					if err != nil {
+						logger.Error("Error committing checkpoint", zap.Error(err))
+						return err
+					}
+					if s.results != nil {
+						s.results.written(s.Id, inputBatch.Partition, metrics, time.Since(started))
+					}
+					s.listeners.emit(Event{
+						Type:      EventBatchWritten,
+						Run:       s.runId,
+						Shard:     s.Id,
+						Partition: inputBatch.Partition,
+						Offset:    inputBatch.Offset,
+						Metrics:   metrics,
+						Duration:  time.Since(written),
+					})
+					if done {
+						s.partitionDone(inputBatch.Partition, inputBatch.Offset)
+					}
 					notifyUpdateTo(metrics)
 				}
 			}
@@ -108,12 +246,42 @@ func (s *ShardWorker[T]) Consume(
 	readBatchSize int,
 	notifyUpdateTo func(WorkerMetrics),
 	maxBatchesPerChunk int,
+) error {
+	return shard.ConsumeWith(ctx, s, readParallelism, readBatchSize, notifyUpdateTo, maxBatchesPerChunk)
+}
+
+// ConsumeShard reads the partitions of shard into the buffer of s, handing
+// every chunk of partitions the resource the shard opened for it.
+func ConsumeShard[T any, R any](
+	ctx context.Context,
+	s *ShardWorker[T],
+	shard ResourceShard[T, R],
+	readParallelism int,
+	readBatchSize int,
+	notifyUpdateTo func(WorkerMetrics),
+	maxBatchesPerChunk int,
 ) error {
 	defer close(s.buffer)
 	partitions, err := shard.Partitions()
 	if err != nil {
 		return err
 	}
+	if s.checkpoints.store != nil {
+		var done []ElementPartition[T, R]
+		partitions, done, err = restorePartitions(s.checkpoints, partitions)
+		if err != nil {
+			return err
+		}
+		for _, partition := range done {
+			s.logger.Info("Partition already done, skipping", zap.String("partition", partition.Id()))
+			_ = partition.Close()
+		}
+	}
+	if len(partitions) == 0 {
+		s.logger.Info("Shard has no partitions to consume")
+		return nil
+	}
+	throttler, _ := shard.(ThrottledShard)
 	parallelism := min(readParallelism, len(partitions))
 	chunks := buildEqualChunks(partitions, parallelism)
 	s.logger.Info("Shard Consumer chunks", zap.Int("partitions", len(partitions)), zap.Int("chunks", len(chunks)), zap.Int("parallelism", parallelism))
@@ -121,25 +289,45 @@ func (s *ShardWorker[T]) Consume(
 	for i, partitionsInChunk := range chunks {
 		logger := s.logger.With(zap.Int("chunk", i))
 		tasks.Go(func() error {
-
-			defer func(parts []ElementPartition[T]) {
+			lease, err := shard.NewResource()
+			defer func(lease *Lease[R]) {
+				if lease != nil {
+					_ = lease.Close()
+				}
+			}(lease)
+			// partitions close first, while the resource they read with is held
+			defer func(parts []ElementPartition[T, R]) {
 				for _, partition := range parts {
 					_ = partition.Close()
 				}
 			}(chunks[i])
-
-			resource, err := shard.NewResource()
 			if err != nil {
-				return err
-			}
-			defer func(resource Closeable) {
-				if resource != nil {
-					_ = resource.Close()
+				if s.failures == nil {
+					return err
+				}
+				logger.Error("Error opening shard resource, failing chunk partitions", zap.Error(err))
+				for _, partition := range partitionsInChunk {
+					s.failPartition(partition, err)
 				}
-			}(resource)
+				return nil
+			}
+
+			var resource R
+			if lease != nil {
+				resource = lease.Resource
+			}
 
 			logger.Info("Starting Shard Consumer chunk", zap.Int("partitions", len(partitionsInChunk)))
+			for _, partition := range partitionsInChunk {
+				s.listeners.emit(Event{
+					Type:      EventPartitionOpened,
+					Run:       s.runId,
+					Shard:     s.Id,
+					Partition: partition.Id(),
+				})
+			}
 
+			failed := make(map[string]bool)
 			batchesToBeFetched := 0
 			pendingWork := true
 			for pendingWork && (maxBatchesPerChunk == 0 || batchesToBeFetched <= maxBatchesPerChunk) {
@@ -156,27 +344,96 @@ func (s *ShardWorker[T]) Consume(
 					default:
 					}
 
+					if failed[partition.Id()] {
+						continue
+					}
 					if !partition.Done() {
 						pendingWork = true
-						recordsBatch, offset, err := partition.NextBatch(resource, readBatchSize)
+						if s.gate != nil {
+							if err := s.gate.Wait(ctx); err != nil {
+								return nil
+							}
+						}
+						var throttled time.Duration
+						if throttler != nil {
+							if throttled, err = throttler.Throttle(ctx); err != nil {
+								if ctx.Err() != nil {
+									return nil
+								}
+								if s.failures == nil {
+									return err
+								}
+								logger.Error("Error throttling partition, skipping it", zap.String("partition", partition.Id()), zap.Error(err))
+								s.failPartition(partition, err)
+								failed[partition.Id()] = true
+								continue
+							}
+						}
+						started := time.Now()
+						recordsBatch, offset, err := nextBatch(ctx, s, partition, resource, readBatchSize)
+						elapsed := time.Since(started)
 						batchesToBeFetched++
-						if err != nil {
-							return err
+						if err != nil && ctx.Err() != nil {
+							// a read cut short by shutdown resumes from its checkpoint
+							return nil
 						}
-						if recordsBatch == nil {
+						if err != nil {
+							if s.failures == nil {
+								return err
+							}
+							logger.Error("Error reading partition, skipping it", zap.String("partition", partition.Id()), zap.Error(err))
+							s.failPartition(partition, err)
+							failed[partition.Id()] = true
 							continue
 						}
-						s.buffer <- PartitionRecordBatch[T]{
-							Shard:     s.Id,
-							Partition: partition.Id(),
-							Offset:    offset,
-							Records:   recordsBatch,
+						if recordsBatch != nil {
+							batch := PartitionRecordBatch[T]{
+								Shard:     s.Id,
+								Partition: partition.Id(),
+								Offset:    offset,
+								Records:   recordsBatch,
+							}
+							batch.seq = s.checkpoints.next(partition.Id())
+							select {
+							case s.buffer <- batch:
+							case <-ctx.Done():
+								return nil
+							}
+							metrics := WorkerMetrics{
+								Processed: len(recordsBatch),
+								Successes: len(recordsBatch),
+								Errors:    0,
+								Throttled: throttled,
+							}
+							if s.results != nil {
+								s.results.read(s.Id, partition.Id(), metrics, elapsed)
+							}
+							s.listeners.emit(Event{
+								Type:      EventBatchRead,
+								Run:       s.runId,
+								Shard:     s.Id,
+								Partition: partition.Id(),
+								Offset:    offset,
+								Metrics:   metrics,
+								Duration:  elapsed,
+							})
+							notifyUpdateTo(metrics)
+						} else if throttled > 0 {
+							notifyUpdateTo(WorkerMetrics{Throttled: throttled})
+						}
+						if partition.Done() {
+							done, err := s.checkpoints.finish(partition.Id())
+							if err != nil {
+								return err
+							}
+							if done {
+								s.partitionDone(partition.Id(), s.checkpoints.offset(partition.Id()))
+							}
+						}
+						if isExclusive(partition) && !partition.Done() {
+							// the next partitions of the chunk wait for this one
+							break
 						}
-						notifyUpdateTo(WorkerMetrics{
-							Processed: len(recordsBatch),
-							Successes: len(recordsBatch),
-							Errors:    0,
-						})
 					} else {
 						logger.Info("Partition done in chunk", zap.String("partition", partition.Id()))
 					}
@@ -190,6 +447,144 @@ func (s *ShardWorker[T]) Consume(
 	return err
 }
 
+func isExclusive[T any, R any](partition ElementPartition[T, R]) bool {
+	exclusive, ok := partition.(ExclusivePartition)
+	return ok && exclusive.Exclusive()
+}
+
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func nextBatch[T any, R any](ctx context.Context, s *ShardWorker[T], partition ElementPartition[T, R], resource R, batchSize int) ([]*T, interface{}, error) {
+	ctx, cancel := withOptionalTimeout(ctx, s.readTimeout)
+	defer cancel()
+	return PartitionWithContext(partition).NextBatchContext(ctx, resource, batchSize)
+}
+
+func (s *ShardWorker[T]) processBatch(ctx context.Context, processor ContextElementProcessor[T], records []*T) ([]*ProcessedRecord, error) {
+	ctx, cancel := withOptionalTimeout(ctx, s.processTimeout)
+	defer cancel()
+	return processor.ProcessBatchContext(ctx, records)
+}
+
+func (s *ShardWorker[T]) recordOutput(writer interface{}) {
+	if s.results != nil {
+		s.results.output(writer)
+	}
+}
+
+func (s *ShardWorker[T]) failPartition(partition interface{ Id() string }, err error) {
+	s.failures.Add(PartitionFailure{
+		Shard:     s.Id,
+		Partition: partition.Id(),
+		Stage:     StageRead,
+		Err:       err,
+	})
+}
+
+func batchLabels[T any](records []*T) Labels {
+	if len(records) == 0 {
+		return nil
+	}
+	if labeled, ok := any(records[0]).(Labeled); ok {
+		return labeled.RecordLabels()
+	}
+	return nil
+}
+
+func appendRecord(sink ElementWriter, output *ProcessedRecord) error {
+	if labeledSink, ok := sink.(LabeledWriter); ok && len(output.Labels) > 0 {
+		return labeledSink.AppendLabeled(output.Id, output.Labels, output.Record)
+	}
+	return sink.Append(output.Id, output.Record)
+}
+
+func appendError(sink ElementWriter, output *ProcessedRecord) error {
+	if labeledSink, ok := sink.(LabeledWriter); ok && len(output.Labels) > 0 {
+		return labeledSink.AppendErrorLabeled(output.Id, output.Labels, output.Err)
+	}
+	return sink.AppendError(output.Id, output.Err)
+}
+
+// Keyed is implemented by records carrying their own id, which processors
+// may report as the Id of their outputs.
+type Keyed interface {
+	RecordKey() any
+}
+
+// fillInputs sets the Input of failed outputs lacking one, so their dead
+// letters can be replayed. Outputs are matched to the records of the batch by
+// Id, either the record id of the batch or the RecordKey of a Keyed record,
+// then by position when there is one output per record.
+func fillInputs[T any](batch *PartitionRecordBatch[T], outputs []*ProcessedRecord) {
+	var byId map[string]*T
+	for i, output := range outputs {
+		if output.Err == nil || output.Input != nil {
+			continue
+		}
+		if byId == nil {
+			byId = make(map[string]*T, 2*len(batch.Records))
+			for j, record := range batch.Records {
+				byId[batch.RecordId(j)] = record
+				if keyed, ok := any(record).(Keyed); ok {
+					byId[fmt.Sprint(keyed.RecordKey())] = record
+				}
+			}
+		}
+		if record, ok := byId[fmt.Sprint(output.Id)]; ok {
+			output.Input = record
+		} else if len(outputs) == len(batch.Records) {
+			output.Input = batch.Records[i]
+		}
+	}
+}
+
+func (s *ShardWorker[T]) deadLetter(batch *PartitionRecordBatch[T], output *ProcessedRecord) *DeadLetter {
+	deadLetter := NewDeadLetter(batch, output.Id, output.Input, output.Err)
+	deadLetter.Run = s.runId
+	deadLetter.ReplayOf = s.replayOf
+	return deadLetter
+}
+
+// commitBatch acknowledges a written batch, flushing the sink and dead
+// letters first when its offset is about to be checkpointed, and reports
+// whether the partition is done. deadLetters may be nil.
+func (s *ShardWorker[T]) commitBatch(batch PartitionRecordBatch[T], sink ElementWriter, deadLetters DeadLetterWriter) (bool, error) {
+	if s.checkpoints.store != nil {
+		if err := flush(sink); err != nil {
+			return false, err
+		}
+		if deadLetters != nil {
+			if err := flush(deadLetters); err != nil {
+				return false, err
+			}
+		}
+	}
+	return s.checkpoints.ack(batch.Partition, batch.seq, batch.Offset)
+}
+
+func flush(writer interface{}) error {
+	if flusher, ok := writer.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+func (s *ShardWorker[T]) partitionDone(partition string, offset interface{}) {
+	s.logger.Info("Partition done", zap.String("partition", partition))
+	s.listeners.emit(Event{
+		Type:      EventPartitionDone,
+		Run:       s.runId,
+		Shard:     s.Id,
+		Partition: partition,
+		Offset:    offset,
+	})
+}
+
 func buildEqualChunks[T any](items []T, numChunks int) [][]T {
 	chunkSize := max(len(items)/numChunks, 1)
 	var chunks [][]T
@@ -0,0 +1,180 @@
+package etl
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// blockingPartition reads until its context is done.
+type blockingPartition struct {
+	id string
+}
+
+func (b *blockingPartition) Id() string {
+	return b.id
+}
+
+func (b *blockingPartition) Done() bool {
+	return false
+}
+
+func (b *blockingPartition) NextBatch(resource NoResource, batchSize int) ([]*int, interface{}, error) {
+	return b.NextBatchContext(context.Background(), resource, batchSize)
+}
+
+func (b *blockingPartition) NextBatchContext(ctx context.Context, resource NoResource, batchSize int) ([]*int, interface{}, error) {
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+func (b *blockingPartition) Close() error {
+	return nil
+}
+
+// blockingProcessor processes until its context is done.
+type blockingProcessor struct {
+	passThrough[int]
+}
+
+func (b blockingProcessor) ProcessBatchContext(ctx context.Context, records []*int) ([]*ProcessedRecord, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// closeLog records the order in which a shard's resource and partitions close.
+type closeLog struct {
+	mu     sync.Mutex
+	closed []string
+}
+
+func (c *closeLog) add(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = append(c.closed, name)
+	return nil
+}
+
+type loggedPartition struct {
+	SlicePartition[int]
+	log *closeLog
+}
+
+func (p *loggedPartition) Close() error {
+	return p.log.add(p.Id())
+}
+
+type loggedShard struct {
+	log        *closeLog
+	partitions []ElementPartition[int, NoResource]
+}
+
+func (s *loggedShard) Id() string {
+	return "shard"
+}
+
+func (s *loggedShard) NewResource() (*Lease[NoResource], error) {
+	return &Lease[NoResource]{release: func() error { return s.log.add("resource") }}, nil
+}
+
+func (s *loggedShard) Partitions() ([]ElementPartition[int, NoResource], error) {
+	return s.partitions, nil
+}
+
+func (s *loggedShard) ConsumeWith(ctx context.Context, worker *ShardWorker[int], readParallelism int, readBatchSize int, notifyUpdateTo func(WorkerMetrics), maxBatchesPerChunk int) error {
+	return ConsumeShard[int, NoResource](ctx, worker, s, readParallelism, readBatchSize, notifyUpdateTo, maxBatchesPerChunk)
+}
+
+func TestShardWorker(t *testing.T) {
+	t.Run("TestAdaptersObserveContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		partition := PartitionWithContext[int, NoResource](&SlicePartition[int]{id: "p", data: []*int{new(int)}})
+		_, _, err := partition.NextBatchContext(ctx, NoResource{}, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+		records, _, err := partition.NextBatchContext(context.Background(), NoResource{}, 1)
+		assert.NoError(t, err)
+		assert.Len(t, records, 1)
+
+		processor := ProcessorWithContext[int](passThrough[int]{})
+		_, err = processor.ProcessBatchContext(ctx, records)
+		assert.ErrorIs(t, err, context.Canceled)
+		processed, err := processor.ProcessBatchContext(context.Background(), records)
+		assert.NoError(t, err)
+		assert.Len(t, processed, 1)
+
+		blocking := &blockingPartition{id: "p"}
+		assert.Same(t, blocking, PartitionWithContext[int, NoResource](blocking))
+		_, isBlocking := ProcessorWithContext[int](blockingProcessor{}).(blockingProcessor)
+		assert.True(t, isBlocking, "context processors are used as they are")
+	})
+
+	t.Run("TestReadTimeoutFailsPartition", func(t *testing.T) {
+		shard, err := NewFilesShard[int]("shard", []ElementPartition[int, NoResource]{&blockingPartition{id: "slow.table"}})
+		assert.NoError(t, err)
+		var report FailureReport
+		worker := NewShardWorker[int]("shard", 1, zap.NewNop()).
+			WithTimeouts(10*time.Millisecond, 0).
+			WithFailureReport(&report)
+		err = worker.Consume(context.Background(), shard, 1, 10, func(WorkerMetrics) {}, 0)
+		assert.NoError(t, err)
+		assert.Len(t, report.Failures(), 1)
+		assert.ErrorIs(t, report.Failures()[0].Err, context.DeadlineExceeded)
+	})
+
+	t.Run("TestShutdownDuringReadIsNotAFailure", func(t *testing.T) {
+		shard, err := NewFilesShard[int]("shard", []ElementPartition[int, NoResource]{&blockingPartition{id: "slow.table"}})
+		assert.NoError(t, err)
+		var report FailureReport
+		worker := NewShardWorker[int]("shard", 1, zap.NewNop()).WithFailureReport(&report)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.NoError(t, worker.Consume(ctx, shard, 1, 10, func(WorkerMetrics) {}, 0))
+		assert.Empty(t, report.Failures())
+
+		worker = NewShardWorker[int]("shard", 1, zap.NewNop())
+		assert.NoError(t, worker.Consume(ctx, shard, 1, 10, func(WorkerMetrics) {}, 0), "nor when failing fast")
+	})
+
+	t.Run("TestProcessTimeoutFailsRecords", func(t *testing.T) {
+		writer := newMemoryWriter()
+		worker := NewShardWorker[int]("shard", 1, zap.NewNop()).WithTimeouts(0, 10*time.Millisecond)
+		worker.buffer <- PartitionRecordBatch[int]{Shard: "shard", Partition: "p", Records: []*int{new(int), new(int)}}
+		close(worker.buffer)
+		err := worker.Produce(context.Background(), blockingProcessor{}, func(string) (ElementWriter, error) { return writer, nil }, 1, func(WorkerMetrics) {})
+		assert.NoError(t, err)
+		assert.Len(t, writer.errors, 2)
+		for _, recordErr := range writer.errors {
+			assert.ErrorIs(t, recordErr, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("TestShutdownDuringProcessingWritesNothing", func(t *testing.T) {
+		writer := newMemoryWriter()
+		worker := NewShardWorker[int]("shard", 1, zap.NewNop())
+		worker.buffer <- PartitionRecordBatch[int]{Shard: "shard", Partition: "p", Records: []*int{new(int)}}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := worker.Produce(ctx, blockingProcessor{}, func(string) (ElementWriter, error) { return writer, nil }, 1, func(WorkerMetrics) {})
+		assert.NoError(t, err)
+		assert.Empty(t, writer.errors)
+		assert.Empty(t, writer.records)
+	})
+
+	t.Run("TestPartitionsCloseBeforeTheirResource", func(t *testing.T) {
+		log := &closeLog{}
+		shard := &loggedShard{log: log}
+		for _, id := range []string{"a", "b"} {
+			shard.partitions = append(shard.partitions, &loggedPartition{SlicePartition: SlicePartition[int]{id: id, data: []*int{new(int)}}, log: log})
+		}
+		worker := NewShardWorker[int]("shard", 10, zap.NewNop())
+		assert.NoError(t, worker.Consume(context.Background(), shard, 1, 10, func(WorkerMetrics) {}, 0))
+		assert.Equal(t, "resource", log.closed[len(log.closed)-1])
+		assert.Contains(t, log.closed, "a")
+		assert.Contains(t, log.closed, "b")
+	})
+}